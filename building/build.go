@@ -5,9 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sklair/building/frontMatter"
 	"sklair/building/hooks"
 	"sklair/building/priorities"
+	"sklair/building/siteData"
+	"sklair/building/sitemap"
+	"sklair/building/templating"
 	"sklair/caching"
 	"sklair/devserver"
 	"sklair/discovery"
@@ -17,17 +22,21 @@ import (
 	"sklair/sklairConfig"
 	"sklair/snippets"
 	"sklair/util"
+	"slices"
 	"strings"
 	"time"
 
 	"golang.org/x/net/html"
 )
 
-func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverride string) error {
+// Build compiles the project into its output directory, or into outputDirOverride for the dev server.
+// liveReload injects the dev server's websocket script into every page, and is only used together with outputDirOverride.
+func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverride string, liveReload bool) error {
 	start := time.Now()
 
 	inputDir := filepath.Join(configDir, config.Input)
 	componentsDir := filepath.Join(configDir, config.Components)
+	dataDir := filepath.Join(configDir, config.Data)
 	hooksPath := ""
 	if config.Hooks != nil && config.Hooks.Enabled {
 		hooksPath = config.Hooks.Path
@@ -49,7 +58,11 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 	if err != nil {
 		return errors.New("could not get relative path for components or hooks : " + err.Error())
 	}
-	excludes := append(config.Exclude, componentsRel, hooksRel)
+	dataRel, err := filepath.Rel(inputDir, dataDir)
+	if err != nil {
+		return errors.New("could not get relative path for data : " + err.Error())
+	}
+	excludes := append(config.Exclude, componentsRel, hooksRel, dataRel)
 
 	if outputDirOverride == "" {
 		outputRel, err := filepath.Rel(inputDir, outputDir)
@@ -84,31 +97,109 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 		return errors.New("could not scan components : " + err.Error())
 	}
 
+	logger.Info("Loading data files...")
+	data, err := siteData.Load(dataDir)
+	if err != nil {
+		return errors.New("could not load data files : " + err.Error())
+	}
+
+	profile := sklairConfig.ProfileProduction
+	if outputDirOverride != "" {
+		profile = sklairConfig.ProfileDevelopment
+	}
+
+	sitemapEnabled := config.Sitemap != nil && config.Sitemap.Enabled
+	var siteURL string
+	if sitemapEnabled {
+		siteURL, err = requireSiteURL(config, "the sitemap")
+		if err != nil {
+			return err
+		}
+	}
+	if len(config.Feeds) > 0 {
+		siteURL, err = requireSiteURL(config, "feeds")
+		if err != nil {
+			return err
+		}
+	}
+
+	markdownEnabled := config.Markdown != nil && config.Markdown.Enabled
+	if !markdownEnabled {
+		scanned.StaticFiles = append(scanned.StaticFiles, scanned.MarkdownFiles...)
+		scanned.MarkdownFiles = nil
+	}
+
+	buildInfo := &luaSandbox.BuildInfo{
+		Config:     config,
+		Profile:    profile,
+		Components: components,
+		Data:       data,
+	}
+	for _, filePath := range scanned.HtmlFiles {
+		relPath, err := filepath.Rel(inputDir, filePath)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s : %s", filePath, err.Error())
+		}
+		buildInfo.HtmlFiles = append(buildInfo.HtmlFiles, filepath.ToSlash(relPath))
+	}
+	for _, filePath := range scanned.MarkdownFiles {
+		relPath, err := filepath.Rel(inputDir, filePath)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s : %s", filePath, err.Error())
+		}
+		buildInfo.MarkdownFiles = append(buildInfo.MarkdownFiles, filepath.ToSlash(relPath))
+	}
+	for _, filePath := range scanned.StaticFiles {
+		relPath, err := filepath.Rel(inputDir, filePath)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s : %s", filePath, err.Error())
+		}
+		buildInfo.StaticFiles = append(buildInfo.StaticFiles, filepath.ToSlash(relPath))
+	}
+
 	// TODO: hooks are really messy here, especially the allHooks variable (potential nil reference later)
 	// so later rewrite some of it to be more readable and less error prone
 	// perhaps just abstract the entire hooks system into a function dedicated for this build step only?
 	// also rename the luaSandbox package to "hooks" because it makes more sense (or maybe dont)
 	hasHooks := config.Hooks != nil && config.Hooks.Enabled
 	var allHooks *discovery.Hookset
+	var compiledPageHooks *hooks.CompiledHooks
+	var hookWarnings, hookErrors int
 	preHookStart := time.Now()
 	if hasHooks {
 		logger.Info("Indexing hooks...")
-		allHooks, err = discovery.DiscoverHooks(hooksDir)
+		allHooks, err = discovery.DiscoverHooks(hooksDir, profile)
 		if err != nil {
 			return errors.New("could not scan hooks : " + err.Error())
 		}
 
 		logger.Info("Running pre-build hooks...")
-		err = hooks.RunHooks(hooksDir, allHooks.PreBuild, &luaSandbox.FSContext{
-			CacheDir:     cacheDir,
-			ProjectDir:   inputDir,
-			TempDir:      tempDir,
-			GeneratedDir: generatedDir,
-			BuiltDir:     outputDir,
-			Mode:         luaSandbox.HookModePre,
+		report, err := hooks.RunHooks(hooksDir, allHooks.PreBuild, luaSandbox.SandboxOptions{
+			FSContext: luaSandbox.FSContext{
+				CacheDir:     cacheDir,
+				ProjectDir:   inputDir,
+				TempDir:      tempDir,
+				GeneratedDir: generatedDir,
+				BuiltDir:     outputDir,
+				Mode:         luaSandbox.HookModePre,
+			},
+			HttpContext: luaSandbox.HttpContextFromConfig(config.Hooks.Http, luaSandbox.HookModePre),
+			BuildInfo:   buildInfo,
 		})
+		report.Log(logger.Info)
 		if err != nil {
-			return fmt.Errorf("could not run pre-build hooks : %s", err.Error())
+			return hookError(configDir, "", fmt.Errorf("could not run pre-build hooks : %w", err))
+		}
+		hookWarnings += report.Warnings()
+		hookErrors += report.Errors()
+		if config.Hooks.FailOnError && report.Errors() > 0 {
+			return fmt.Errorf("pre-build hooks logged %d errors", report.Errors())
+		}
+
+		// page hooks run for every document, so they are only read and compiled once
+		compiledPageHooks, err = hooks.CompileHooks(hooksDir, luaSandbox.HookModePage, allHooks.Page)
+		if err != nil {
+			return hookError(configDir, "", fmt.Errorf("could not compile page hooks : %w", err))
 		}
 	}
 	preHookEnd := time.Since(preHookStart)
@@ -127,22 +218,30 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 		}
 	}
 
-	compilationStart := time.Now()
+	var pageHookTotal time.Duration
+	pageHookDocuments := 0
 
-	logger.Info("Resolving components usage and compiling...")
-	for _, filePath := range scanned.HtmlFiles {
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("could not read file %s : %s", filePath, err.Error())
-		}
+	compilationStart := time.Now()
 
-		//logger.Debug("File %s : %s", filePath, string(content))
+	logger.Info("Loading collections...")
+	outputs := append(slices.Clone(buildInfo.HtmlFiles), buildInfo.StaticFiles...)
+	for _, relPath := range buildInfo.MarkdownFiles {
+		outputs = append(outputs, strings.TrimSuffix(relPath, path.Ext(relPath))+".html")
+	}
+	pages := append(slices.Clone(scanned.HtmlFiles), scanned.MarkdownFiles...)
+	collections, generated, err := loadCollections(config.Collections, inputDir, pages, data, outputs)
+	if err != nil {
+		return errors.New("could not load collections : " + err.Error())
+	}
 
-		doc, err := html.Parse(bytes.NewReader(content))
-		if err != nil {
-			return fmt.Errorf("could not parse file %s : %s", filePath, err.Error())
-		}
+	// filled in by compileDocument, because the sitemap needs to know what is inside of each page
+	sitemapPages := map[string]sitemap.Page{}
 
+	// compileDocument expands the components of a single document, fills in its template variables,
+	// runs the page hooks on it if requested, optimises its head and writes it to relPath inside the output directory.
+	// vars are added to the variables that every page has, i.e. the item of a generated page.
+	// filePath is only used for error messages
+	compileDocument := func(filePath string, relPath string, doc *html.Node, page frontMatter.FrontMatter, vars templating.Context, pageHooks bool) error {
 		var toReplace []*html.Node
 
 		for node := range doc.Descendants() {
@@ -163,7 +262,7 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 						logger.Info("Processing and caching tag %s...", tag)
 						cached, err := caching.MakeCache(componentsDir, componentSrc)
 						if err != nil {
-							return fmt.Errorf("could not cache component %s : %s", componentSrc, err.Error())
+							return fileError(configDir, filepath.Join(componentsDir, componentSrc), fmt.Errorf("could not cache component : %s", err.Error()))
 						}
 
 						if cached.Dynamic {
@@ -186,7 +285,7 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 		head := htmlUtilities.FindTag(doc, "head")
 		body := htmlUtilities.FindTag(doc, "body")
 		if head == nil || body == nil {
-			return fmt.Errorf("could not find head or body tags in %s, how does that even happen", filePath)
+			return fileError(configDir, filePath, errors.New("could not find head or body tags, how does that even happen"))
 		}
 
 		// usedComponents ensures that each component contributes its <head> nodes at most ONCE per document,
@@ -230,7 +329,11 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 				logger.Warning("Lua components for regular input files are not implemented yet, skipping...")
 				continue
 			} else if originalTag.Data == "opengraph" {
-				for _, child := range snippets.OpenGraph(originalTag) {
+				for _, child := range snippets.OpenGraph(originalTag, map[string]string{
+					"title":       page.String("title"),
+					"description": page.String("description"),
+					"image":       page.String("image"),
+				}) {
 					head.AppendChild(child)
 				}
 				parent.RemoveChild(originalTag)
@@ -240,6 +343,71 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 			}
 		}
 
+		// --------------------------------------------------
+		// templating
+		// --------------------------------------------------
+		// after component expansion, so that components can use the variables of the page they are in
+		pageVars := make(map[string]any, len(page)+2)
+		for k, v := range page {
+			pageVars[k] = v
+		}
+		pageVars["path"] = filepath.ToSlash(relPath)
+		pageVars["url"] = pageURL(relPath)
+
+		templateVars := templating.Context{"page": pageVars, "data": data, "collections": collections}
+		for k, v := range vars {
+			templateVars[k] = v
+		}
+
+		missing, err := templateVars.Apply(doc)
+		if err != nil {
+			return fileError(configDir, filePath, err)
+		}
+		for _, name := range missing {
+			logger.Warning("%s uses the undefined variable %s", filepath.ToSlash(relPath), name)
+		}
+
+		// --------------------------------------------------
+		// page hooks
+		// --------------------------------------------------
+		if pageHooks && hasHooks && len(allHooks.Page) > 0 {
+			pageHookStart := time.Now()
+			report, err := compiledPageHooks.Run(luaSandbox.SandboxOptions{
+				FSContext: luaSandbox.FSContext{
+					CacheDir:     cacheDir,
+					ProjectDir:   inputDir,
+					TempDir:      tempDir,
+					GeneratedDir: generatedDir,
+					BuiltDir:     outputDir,
+					Mode:         luaSandbox.HookModePage,
+				},
+				HttpContext: luaSandbox.HttpContextFromConfig(config.Hooks.Http, luaSandbox.HookModePage),
+				BuildInfo:   buildInfo,
+				Page: &luaSandbox.PageContext{
+					Path:     filepath.ToSlash(relPath),
+					Document: doc,
+				},
+			})
+			report.Log(logger.Debug)
+			if err != nil {
+				return hookError(configDir, filePath, fmt.Errorf("could not run page hooks for %s : %w", relPath, err))
+			}
+			hookWarnings += report.Warnings()
+			hookErrors += report.Errors()
+			if config.Hooks.FailOnError && report.Errors() > 0 {
+				return fileError(configDir, filePath, fmt.Errorf("page hooks logged %d errors", report.Errors()))
+			}
+			pageHookTotal += time.Since(pageHookStart)
+			pageHookDocuments++
+
+			// hooks are free to restructure the document, so the old references may be stale now
+			head = htmlUtilities.FindTag(doc, "head")
+			body = htmlUtilities.FindTag(doc, "body")
+			if head == nil || body == nil {
+				return fileError(configDir, filePath, errors.New("page hooks removed the head or body tags"))
+			}
+		}
+
 		// --------------------------------------------------
 		// resource hints
 		// --------------------------------------------------
@@ -266,7 +434,7 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 		// --------------------------------------------------
 		segmentedHead, err := SegmentHead(head)
 		if err != nil {
-			return fmt.Errorf("could not segment <head> in %s : %s", filePath, err.Error())
+			return fileError(configDir, filePath, fmt.Errorf("could not segment <head> : %s", err.Error()))
 		}
 
 		if config.PreventFOUC != nil && config.PreventFOUC.Enabled {
@@ -286,7 +454,7 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 			IsOrderingBarrier: false,
 		})
 
-		if outputDirOverride != "" {
+		if outputDirOverride != "" && liveReload {
 			// sklair dev server refresh with websocket
 			segmentedHead = append(segmentedHead, &HeadSegment{
 				Nodes: []*html.Node{
@@ -307,15 +475,50 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 			}
 		}
 
+		lastMod, _ := page["updated"].(time.Time)
+		if lastMod.IsZero() {
+			lastMod, _ = page["date"].(time.Time)
+		}
+		sitemapPages[filepath.ToSlash(relPath)] = sitemap.Page{
+			RelPath: filepath.ToSlash(relPath),
+			Path:    pageURL(relPath),
+			LastMod: lastMod,
+			NoIndex: sitemap.NoIndex(doc) || isNotFoundPage(relPath), // 404 pages are served for missing urls, not at their own
+		}
+
+		if isNotFoundPage(relPath) && config.RootAnchor404 {
+			if n := rootAnchorURLs(doc, relPath); n > 0 {
+				logger.Info("Root-anchored %d relative URLs in %s", n, filepath.ToSlash(relPath))
+			}
+		}
+
 		newWriter := bytes.NewBuffer(nil)
 		err = html.Render(newWriter, doc)
 		if err != nil {
 			return fmt.Errorf("could not render output for %s : %s", filePath, err.Error())
 		}
 
-		relPath, err := filepath.Rel(inputDir, filePath)
-		if err != nil {
-			return fmt.Errorf("could not get relative path for %s : %s", filePath, err.Error())
+		// the compiled page is scanned, because relative urls often come from components rather than the page itself.
+		// the warnings still point at the source file, or at least name it when the url is not written there
+		if isNotFoundPage(relPath) && !config.RootAnchor404 {
+			relative := relativeReferences(newWriter.Bytes())
+			if len(relative) > 0 {
+				source, _ := os.ReadFile(filePath)
+				sourcePath := filePath
+				if rel, err := filepath.Rel(configDir, filePath); err == nil {
+					sourcePath = rel
+				}
+				sourcePath = filepath.ToSlash(sourcePath)
+
+				for _, ref := range relative {
+					if line := sourceLine(source, ref.URL); line > 0 {
+						logger.Warning("%s:%d : the relative URL %s breaks when the 404 page is served for a missing page inside of a directory", sourcePath, line, ref.URL)
+					} else {
+						logger.Warning("%s : the relative URL %s, which comes from a component or layout, breaks when the 404 page is served for a missing page inside of a directory", sourcePath, ref.URL)
+					}
+				}
+				logger.Warning("Use root-anchored URLs such as /styles/main.css in 404 pages, or set \"rootAnchor404\": true in sklair.json to rewrite them automatically")
+			}
 		}
 
 		outPath := filepath.Join(outputDir, relPath)
@@ -330,11 +533,115 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 		}
 
 		logger.Info("Saved to %s", outPath)
+		return nil
 	}
 
-	processingEnd := time.Since(compilationStart)
+	logger.Info("Resolving components usage and compiling...")
+	for _, filePath := range scanned.HtmlFiles {
+		relPath, err := filepath.Rel(inputDir, filePath)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s : %s", filePath, err.Error())
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fileError(configDir, filePath, fmt.Errorf("could not read file : %s", err.Error()))
+		}
+
+		doc, page, err := htmlDocument(content, componentsDir, components)
+		if err != nil {
+			return fileError(configDir, filePath, err)
+		}
+
+		if err := compileDocument(filePath, relPath, doc, page, nil, true); err != nil {
+			return err
+		}
+
+		buildInfo.Outputs = append(buildInfo.Outputs, luaSandbox.OutputFile{
+			Source: filepath.ToSlash(relPath),
+			Output: filepath.ToSlash(relPath),
+			Kind:   "html",
+		})
+	}
+
+	for _, filePath := range scanned.MarkdownFiles {
+		relPath, err := filepath.Rel(inputDir, filePath)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s : %s", filePath, err.Error())
+		}
+		outRel := strings.TrimSuffix(relPath, filepath.Ext(relPath)) + ".html"
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return fileError(configDir, filePath, fmt.Errorf("could not read file : %s", err.Error()))
+		}
+
+		doc, page, err := markdownDocument(content, config.Markdown.Layout, componentsDir, components)
+		if err != nil {
+			return fileError(configDir, filePath, err)
+		}
+
+		if err := compileDocument(filePath, outRel, doc, page, nil, true); err != nil {
+			return err
+		}
+
+		buildInfo.Outputs = append(buildInfo.Outputs, luaSandbox.OutputFile{
+			Source: filepath.ToSlash(relPath),
+			Output: filepath.ToSlash(outRel),
+			Kind:   "markdown",
+		})
+	}
+
+	if len(generated) > 0 {
+		logger.Info("Generating %d collection pages...", len(generated))
+	}
+	for _, g := range generated {
+		templatePath := filepath.Join(componentsDir, components[strings.ToLower(g.Template)])
+
+		doc, err := templateDocument(componentsDir, components, g.Template)
+		if err != nil {
+			return fileError(configDir, templatePath, err)
+		}
+
+		if err := compileDocument(templatePath, g.RelPath, doc, g.FrontMatter, g.Vars, true); err != nil {
+			return err
+		}
+
+		// the source is the template, which is only inside of the project directory if the components are
+		source, err := filepath.Rel(inputDir, templatePath)
+		if err != nil {
+			return fmt.Errorf("could not get relative path for %s : %s", templatePath, err.Error())
+		}
+		buildInfo.Outputs = append(buildInfo.Outputs, luaSandbox.OutputFile{
+			Source: filepath.ToSlash(source),
+			Output: g.RelPath,
+			Kind:   "generated",
+		})
+	}
 
+	// the component previews are only for the dev server, so they are never part of buildInfo.Outputs
 	if outputDirOverride != "" {
+		logger.Info("Generating component previews...")
+		previews, err := makePreviews(componentsDir, components)
+		if err != nil {
+			return errors.New("could not generate component previews : " + err.Error())
+		}
+
+		for _, p := range previews {
+			doc, err := html.Parse(bytes.NewReader(p.Content))
+			if err != nil {
+				return fileError(configDir, p.Source, fmt.Errorf("could not parse component preview : %s", err.Error()))
+			}
+
+			if err := compileDocument(p.Source, p.RelPath, doc, frontMatter.FrontMatter{}, nil, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	processingEnd := time.Since(compilationStart)
+
+	if outputDirOverride != "" && liveReload {
 		err = os.MkdirAll(filepath.Join(outputDir, "_sklair"), 0755)
 		if err != nil {
 			return fmt.Errorf("could not create sklair dev server directory : %s", err.Error())
@@ -367,11 +674,71 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 			return fmt.Errorf("could not copy static file %s : %s", filePath, err.Error())
 		}
 
+		buildInfo.Outputs = append(buildInfo.Outputs, luaSandbox.OutputFile{
+			Source: filepath.ToSlash(relPath),
+			Output: filepath.ToSlash(relPath),
+			Kind:   "static",
+		})
+
 		logger.Info("Copied static file to %s", outPath)
 	}
 
 	staticEnd := time.Since(staticStart)
 
+	if len(config.Feeds) > 0 {
+		logger.Info("Generating %d feeds...", len(config.Feeds))
+
+		written, err := writeFeeds(config.Feeds, siteURL, inputDir, outputDir, pages, collections, buildInfo.Outputs)
+		if err != nil {
+			return errors.New("could not generate feeds : " + err.Error())
+		}
+		for _, name := range written {
+			buildInfo.Outputs = append(buildInfo.Outputs, luaSandbox.OutputFile{Output: name, Kind: "generated"})
+		}
+	}
+
+	if sitemapEnabled {
+		logger.Info("Generating sitemap...")
+
+		hasRobots := false
+		var entries []sitemap.Page
+		for _, out := range buildInfo.Outputs {
+			if out.Kind == "static" {
+				hasRobots = hasRobots || out.Output == "robots.txt"
+				continue
+			}
+
+			// feeds are generated too, but they are not pages
+			page, ok := sitemapPages[out.Output]
+			if !ok {
+				continue
+			}
+			if page.LastMod.IsZero() && out.Kind != "generated" {
+				if info, err := os.Stat(filepath.Join(inputDir, out.Source)); err == nil {
+					page.LastMod = info.ModTime()
+				}
+			}
+			entries = append(entries, page)
+		}
+
+		written, err := sitemap.Write(outputDir, siteURL, config.Sitemap, entries)
+		if err != nil {
+			return err
+		}
+		if !hasRobots {
+			if err := sitemap.WriteRobots(outputDir, siteURL); err != nil {
+				return fmt.Errorf("could not write robots.txt : %s", err.Error())
+			}
+			written = append(written, "robots.txt")
+		} else {
+			logger.Info("Keeping the robots.txt of the input directory, make sure that it references %s/sitemap.xml", siteURL)
+		}
+
+		for _, name := range written {
+			buildInfo.Outputs = append(buildInfo.Outputs, luaSandbox.OutputFile{Output: name, Kind: "generated"})
+		}
+	}
+
 	postHookStart := time.Now()
 	if hasHooks {
 		buildSklairDir := filepath.Join(outputDir, "_sklair") // TODO: the _sklair directory in output is not unique to hooks, they will be used for more things in the future
@@ -393,26 +760,41 @@ func Build(config *sklairConfig.ProjectConfig, configDir string, outputDirOverri
 		}
 
 		logger.Info("Running post-build hooks...")
-		err = hooks.RunHooks(hooksDir, allHooks.PostBuild, &luaSandbox.FSContext{
-			CacheDir:     cacheDir,
-			ProjectDir:   inputDir,
-			TempDir:      tempDir,
-			GeneratedDir: buildSklairDir,
-			BuiltDir:     outputDir,
-			Mode:         luaSandbox.HookModePost,
+		report, err := hooks.RunHooks(hooksDir, allHooks.PostBuild, luaSandbox.SandboxOptions{
+			FSContext: luaSandbox.FSContext{
+				CacheDir:     cacheDir,
+				ProjectDir:   inputDir,
+				TempDir:      tempDir,
+				GeneratedDir: buildSklairDir,
+				BuiltDir:     outputDir,
+				Mode:         luaSandbox.HookModePost,
+			},
+			HttpContext: luaSandbox.HttpContextFromConfig(config.Hooks.Http, luaSandbox.HookModePost),
+			BuildInfo:   buildInfo,
 		})
+		report.Log(logger.Info)
 		if err != nil {
-			return fmt.Errorf("could not run post-build hooks : %s", err.Error())
+			return hookError(configDir, "", fmt.Errorf("could not run post-build hooks : %w", err))
+		}
+		hookWarnings += report.Warnings()
+		hookErrors += report.Errors()
+		if config.Hooks.FailOnError && report.Errors() > 0 {
+			return fmt.Errorf("post-build hooks logged %d errors", report.Errors())
 		}
 	}
 	postHookEnd := time.Since(postHookStart)
 
 	//logger.EmptyLine()
-	logger.Info("Compilation (including writes) of %d files : %s", len(scanned.HtmlFiles), processingEnd)
+	logger.Info("Compilation (including writes) of %d files : %s", len(scanned.HtmlFiles)+len(scanned.MarkdownFiles), processingEnd)
 	logger.Info("Static copy of %d files : %s", len(scanned.StaticFiles), staticEnd)
 	if hasHooks {
 		logger.Info("Run time of %d pre-build hooks : %s", len(allHooks.PreBuild), preHookEnd)
+		logger.Info("Run time of %d page hooks across %d documents : %s", len(allHooks.Page), pageHookDocuments, pageHookTotal)
 		logger.Info("Run time of %d post-build hooks : %s", len(allHooks.PostBuild), postHookEnd)
+
+		if hookWarnings > 0 || hookErrors > 0 {
+			logger.Warning("Hooks logged %d warnings and %d errors", hookWarnings, hookErrors)
+		}
 	}
 	logger.Info("Time since start : %s", time.Since(start))
 
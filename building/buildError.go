@@ -0,0 +1,65 @@
+package building
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// BuildError is a build failure that can be attributed to a single file.
+// the dev server shows these in the browser, see devserver/ws_refresh.js
+type BuildError struct {
+	File string // relative to the directory of sklair.json, with forward slashes
+	Line int    // 0 if unknown
+	Err  error
+}
+
+func (e *BuildError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d : %s", e.File, e.Line, e.Err.Error())
+	}
+	return fmt.Sprintf("%s : %s", e.File, e.Err.Error())
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+func fileError(configDir string, file string, err error) *BuildError {
+	if rel, relErr := filepath.Rel(configDir, file); relErr == nil {
+		file = rel
+	}
+
+	return &BuildError{File: filepath.ToSlash(file), Err: err}
+}
+
+// runtime errors look like "path/to/hook.lua:12: message",
+// syntax errors like "path/to/hook.lua line:12(column:3) near 'x': syntax error"
+var luaRuntimeError = regexp.MustCompile(`^(.+?\.lua):(\d+): `)
+var luaSyntaxError = regexp.MustCompile(`^(.+?\.lua) line:(\d+)\(`)
+
+// hookError attributes a failed hook to the line of the lua file that raised the error.
+// errors which did not come from lua are attributed to the page or phase that was being built instead
+func hookError(configDir string, fallback string, err error) error {
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) && apiErr.Object != nil {
+		msg := apiErr.Object.String()
+
+		for _, re := range []*regexp.Regexp{luaRuntimeError, luaSyntaxError} {
+			if m := re.FindStringSubmatch(msg); m != nil {
+				be := fileError(configDir, m[1], err)
+				be.Line, _ = strconv.Atoi(m[2])
+				return be
+			}
+		}
+	}
+
+	if fallback == "" {
+		return err
+	}
+	return fileError(configDir, fallback, err)
+}
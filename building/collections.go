@@ -0,0 +1,482 @@
+package building
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sklair/building/frontMatter"
+	"sklair/building/templating"
+	"sklair/sklairConfig"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// generatedPage is a page that does not exist in the input directory, but is generated from a template component
+type generatedPage struct {
+	Template    string // name of the template component
+	RelPath     string // output path, relative to the output directory
+	Vars        templating.Context
+	FrontMatter frontMatter.FrontMatter // only "updated", the newest date of the items on the page, if any of them has one
+}
+
+type collectionTag struct {
+	name  string
+	slug  string
+	items []any
+}
+
+// pageURL is the root-relative url of an output file, with index.html left out
+func pageURL(relPath string) string {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == "index.html" {
+		return "/"
+	}
+	if strings.HasSuffix(relPath, "/index.html") {
+		return "/" + strings.TrimSuffix(relPath, "index.html")
+	}
+	return "/" + relPath
+}
+
+// slugify turns a tag such as "Release Notes" into something that is safe to use in a path, i.e. "release-notes"
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// compareValues orders front matter or data values, values that do not exist always come last
+func compareValues(a, b any) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		default:
+			return -1
+		}
+	}
+
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			return at.Compare(bt)
+		}
+	}
+
+	if an, ok := numeric(a); ok {
+		if bn, ok := numeric(b); ok {
+			switch {
+			case an < bn:
+				return -1
+			case an > bn:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(templating.Format(a), templating.Format(b))
+}
+
+func numeric(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// itemTags returns the tags of an item, which may be a list or a single string
+func itemTags(item any) []string {
+	fields, ok := item.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	switch tags := fields["tags"].(type) {
+	case string:
+		return []string{tags}
+	case []any:
+		var out []string
+		for _, tag := range tags {
+			out = append(out, templating.Format(tag))
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// itemsFrontMatter is the front matter of a page generated from items, which is updated whenever the newest of them was
+func itemsFrontMatter(items ...any) frontMatter.FrontMatter {
+	var newest time.Time
+	for _, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		for _, key := range []string{"updated", "date"} {
+			if t, ok := frontMatter.ParseDate(fields[key]); ok {
+				if t.After(newest) {
+					newest = t
+				}
+				break
+			}
+		}
+	}
+
+	if newest.IsZero() {
+		return frontMatter.FrontMatter{}
+	}
+	return frontMatter.FrontMatter{"updated": newest}
+}
+
+// outputPath fills in the variables of a configured output path, e.g. "blog/tags/{{ tag.slug }}.html".
+// a path that ends with a slash is a directory, so index.html is added to it
+func outputPath(pattern string, vars templating.Context) (string, error) {
+	p, missing := vars.Interpolate(pattern)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("output path %s uses the undefined variable %s", pattern, missing[0])
+	}
+
+	if strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+
+	if strings.Contains(p, "..") || p == "" {
+		return "", fmt.Errorf("output path %s resolves to %q, which is not inside of the output directory", pattern, p)
+	}
+	if path.Ext(p) != ".html" {
+		return "", fmt.Errorf("output path %s resolves to %s, which does not end with .html", pattern, p)
+	}
+
+	return p, nil
+}
+
+// sourceItems reads the front matter of every page in dir, without compiling them.
+// pages are the html and Markdown files that are going to be built, as absolute paths
+func sourceItems(inputDir string, dir string, pages []string) ([]any, error) {
+	var items []any
+
+	for _, filePath := range pages {
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		name := strings.ToLower(filepath.Base(rel))
+		if filepath.Dir(rel) == "." && strings.TrimSuffix(name, filepath.Ext(name)) == "index" {
+			continue
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		var fm frontMatter.FrontMatter
+		outRel, err := filepath.Rel(inputDir, filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+		case ".md", ".markdown":
+			fm, _, err = frontMatter.Parse(content)
+			outRel = strings.TrimSuffix(outRel, filepath.Ext(outRel)) + ".html"
+		default:
+			fm, _, err = frontMatter.ParseHTML(content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read the front matter of %s : %s", filePath, err.Error())
+		}
+
+		item := make(map[string]any, len(fm)+2)
+		for k, v := range fm {
+			item[k] = v
+		}
+		item["path"] = filepath.ToSlash(outRel)
+		item["url"] = pageURL(outRel)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// loadCollections collects, sorts and tags the items of every collection, and works out which pages have to be generated for them.
+// outputs are the output paths of every regular page and static file, which generated pages may not overwrite.
+// the result is what templates see as collections
+func loadCollections(configs map[string]*sklairConfig.Collection, inputDir string, pages []string, data map[string]any, outputs []string) (map[string]any, []*generatedPage, error) {
+	collections := make(map[string]any, len(configs))
+	var generated []*generatedPage
+
+	taken := make(map[string]string, len(outputs))
+	for _, out := range outputs {
+		taken[out] = "a file in the input directory"
+	}
+	claim := func(relPath string, by string) error {
+		if other, ok := taken[relPath]; ok {
+			return fmt.Errorf("%s would overwrite %s, which already comes from %s", by, relPath, other)
+		}
+		taken[relPath] = by
+		return nil
+	}
+
+	// sorted, so that clashing output paths are always reported the same way
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		c := configs[name]
+		errPrefix := "collection " + name
+		if c == nil {
+			return nil, nil, errors.New(errPrefix + " is empty")
+		}
+
+		var items []any
+		switch {
+		case c.Source != "" && c.Data != "":
+			return nil, nil, errors.New(errPrefix + " can only have either a source or data")
+		case c.Source != "":
+			if c.Template != "" {
+				return nil, nil, errors.New(errPrefix + " can not have an item template, because its pages are already built from the source directory")
+			}
+
+			var err error
+			items, err = sourceItems(inputDir, filepath.Join(inputDir, c.Source), pages)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s : %s", errPrefix, err.Error())
+			}
+		case c.Data != "":
+			value, ok := templating.Context(data).Lookup(c.Data)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s uses data.%s, which does not exist", errPrefix, c.Data)
+			}
+			list, ok := value.([]any)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s uses data.%s, which is not a list", errPrefix, c.Data)
+			}
+
+			// copied, so that adding the url of generated pages does not change data.<list> itself
+			for _, v := range list {
+				if fields, ok := v.(map[string]any); ok {
+					item := make(map[string]any, len(fields)+1)
+					for k, field := range fields {
+						item[k] = field
+					}
+					v = item
+				}
+				items = append(items, v)
+			}
+		default:
+			return nil, nil, errors.New(errPrefix + " needs either a source or data")
+		}
+
+		if c.SortBy != "" {
+			sort.SliceStable(items, func(i, j int) bool {
+				a, _ := templating.Context{"item": items[i]}.Lookup("item." + c.SortBy)
+				b, _ := templating.Context{"item": items[j]}.Lookup("item." + c.SortBy)
+				if c.Reverse {
+					// missing values still come last
+					if a == nil || b == nil {
+						return compareValues(a, b) < 0
+					}
+					return compareValues(a, b) > 0
+				}
+				return compareValues(a, b) < 0
+			})
+		} else if c.Reverse {
+			slices.Reverse(items)
+		}
+
+		if c.Tags != nil && (c.Tags.Template == "" || c.Tags.Path == "") {
+			return nil, nil, errors.New(errPrefix + " needs both a template and an output path for its tag pages")
+		}
+		if c.Paginate != nil && (c.Paginate.Template == "" || c.Paginate.Path == "") {
+			return nil, nil, errors.New(errPrefix + " needs both a template and an output path to be paginated")
+		}
+
+		// item pages
+		if c.Template != "" {
+			if c.Path == "" {
+				return nil, nil, errors.New(errPrefix + " has an item template, but no output path")
+			}
+
+			for _, item := range items {
+				vars := templating.Context{"item": item}
+				relPath, err := outputPath(c.Path, vars)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s : %s", errPrefix, err.Error())
+				}
+				if err := claim(relPath, errPrefix); err != nil {
+					return nil, nil, err
+				}
+
+				if fields, ok := item.(map[string]any); ok {
+					fields["path"] = relPath
+					fields["url"] = pageURL(relPath)
+				}
+				generated = append(generated, &generatedPage{Template: c.Template, RelPath: relPath, Vars: vars, FrontMatter: itemsFrontMatter(item)})
+			}
+		}
+
+		// tags
+		byName := map[string]*collectionTag{}
+		var tags []*collectionTag
+		for _, item := range items {
+			for _, tagName := range itemTags(item) {
+				tag, ok := byName[tagName]
+				if !ok {
+					tag = &collectionTag{name: tagName, slug: slugify(tagName)}
+					byName[tagName] = tag
+					tags = append(tags, tag)
+				}
+				tag.items = append(tag.items, item)
+			}
+		}
+		slices.SortFunc(tags, func(a, b *collectionTag) int {
+			return strings.Compare(a.name, b.name)
+		})
+
+		tagList := make([]any, len(tags))
+		for i, tag := range tags {
+			fields := map[string]any{
+				"name":  tag.name,
+				"slug":  tag.slug,
+				"items": tag.items,
+			}
+			tagList[i] = fields
+
+			if c.Tags == nil {
+				continue
+			}
+
+			vars := templating.Context{"tag": fields}
+			relPath, err := outputPath(c.Tags.Path, vars)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s : %s", errPrefix, err.Error())
+			}
+			if err := claim(relPath, errPrefix+" tag "+tag.name); err != nil {
+				return nil, nil, err
+			}
+
+			fields["path"] = relPath
+			fields["url"] = pageURL(relPath)
+			generated = append(generated, &generatedPage{Template: c.Tags.Template, RelPath: relPath, Vars: vars, FrontMatter: itemsFrontMatter(tag.items...)})
+		}
+
+		// list pages
+		if p := c.Paginate; p != nil {
+			if p.Size < 1 {
+				return nil, nil, errors.New(errPrefix + " needs a page size of at least 1 to be paginated")
+			}
+
+			total := max(1, (len(items)+p.Size-1)/p.Size) // an empty collection still gets a page that says so
+			pagination := make([]map[string]any, total)
+			for i := range pagination {
+				number := i + 1
+				pattern := p.Path
+				if number == 1 && p.FirstPath != "" {
+					pattern = p.FirstPath
+				}
+
+				fields := map[string]any{
+					"number": number,
+					"total":  total,
+					"items":  items[min(i*p.Size, len(items)):min(number*p.Size, len(items))],
+				}
+				relPath, err := outputPath(pattern, templating.Context{"pagination": fields})
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s : %s", errPrefix, err.Error())
+				}
+				if err := claim(relPath, fmt.Sprintf("page %d of collection %s", number, name)); err != nil {
+					return nil, nil, err
+				}
+
+				fields["path"] = relPath
+				fields["url"] = pageURL(relPath)
+				pagination[i] = fields
+			}
+
+			// every page links to its neighbours, which only have their urls once every page is known
+			for i, fields := range pagination {
+				fields["first"] = pagination[0]["url"]
+				fields["last"] = pagination[total-1]["url"]
+				fields["previous"] = ""
+				fields["next"] = ""
+				if i > 0 {
+					fields["previous"] = pagination[i-1]["url"]
+				}
+				if i < total-1 {
+					fields["next"] = pagination[i+1]["url"]
+				}
+
+				generated = append(generated, &generatedPage{
+					Template:    p.Template,
+					RelPath:     fields["path"].(string),
+					Vars:        templating.Context{"pagination": fields},
+					FrontMatter: itemsFrontMatter(fields["items"].([]any)...),
+				})
+			}
+		}
+
+		collections[name] = map[string]any{
+			"items": items,
+			"tags":  tagList,
+		}
+	}
+
+	return collections, generated, nil
+}
+
+// templateDocument parses the component that generated pages are made from.
+// it is a full document, just like a layout, but a content slot is optional because there is no page content to put into it
+func templateDocument(componentsDir string, components map[string]string, name string) (*html.Node, error) {
+	source, err := loadLayout(componentsDir, components, name)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(source))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse template %s : %s", name, err.Error())
+	}
+
+	if slot := findContentSlot(doc); slot != nil {
+		slot.Parent.RemoveChild(slot)
+	}
+
+	return doc, nil
+}
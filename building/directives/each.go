@@ -0,0 +1,31 @@
+package directives
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// IsEachStart detects <!-- sklair:each item in data.nav -->, returning the name of the loop variable and the list it loops over.
+// ok is true for anything that starts with sklair:each, so that a malformed loop can be reported instead of silently ignored
+func IsEachStart(n *html.Node) (ok bool, name string, list string) {
+	if n.Type != html.CommentNode {
+		return false, "", ""
+	}
+
+	text := strings.TrimSpace(n.Data)
+	if text == "sklair:each-end" || !strings.HasPrefix(text, "sklair:each ") {
+		return false, "", ""
+	}
+
+	parts := strings.Fields(strings.TrimPrefix(text, "sklair:each "))
+	if len(parts) != 3 || parts[1] != "in" {
+		return true, "", ""
+	}
+
+	return true, parts[0], parts[2]
+}
+
+func IsEachEnd(n *html.Node) bool {
+	return n.Type == html.CommentNode && strings.TrimSpace(n.Data) == "sklair:each-end"
+}
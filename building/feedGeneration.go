@@ -0,0 +1,212 @@
+package building
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sklair/building/feeds"
+	"sklair/building/frontMatter"
+	"sklair/luaSandbox"
+	"sklair/sklairConfig"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const defaultFeedLimit = 20
+
+// feedEntry turns a collection item into a feed entry, using the compiled page of the item for whatever its fields do not provide.
+// an entry without any date is updated whenever the source file of its page was, if the page has one
+func feedEntry(inputDir string, outputDir string, siteURL string, item map[string]any, defaultAuthor string, sources map[string]string) (*feeds.Entry, error) {
+	relPath, _ := item["path"].(string)
+	if relPath == "" {
+		return nil, errors.New("every entry needs a page, but an item has no path")
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, relPath))
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s : %s", relPath, err.Error())
+	}
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s : %s", relPath, err.Error())
+	}
+
+	field := func(keys ...string) string {
+		for _, key := range keys {
+			if s, ok := item[key].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+
+	entry := &feeds.Entry{
+		URL:     siteURL + (&url.URL{Path: pageURL(relPath)}).EscapedPath(),
+		Title:   field("title"),
+		Summary: field("summary", "description"),
+		Author:  field("author"),
+		Tags:    itemTags(item),
+	}
+	if entry.Author == "" {
+		entry.Author = defaultAuthor
+	}
+
+	title, description, published := feeds.Meta(doc)
+	if entry.Title == "" {
+		entry.Title = title
+	}
+	if entry.Summary == "" {
+		entry.Summary = description
+	}
+
+	entry.Published = published
+	if date, ok := frontMatter.ParseDate(item["date"]); ok {
+		entry.Published = date
+	}
+	entry.Updated = entry.Published
+	if updated, ok := frontMatter.ParseDate(item["updated"]); ok {
+		entry.Updated = updated
+	}
+	if source, ok := sources[relPath]; ok && entry.Updated.IsZero() {
+		if info, err := os.Stat(filepath.Join(inputDir, source)); err == nil {
+			entry.Updated = info.ModTime()
+		}
+	}
+
+	entry.Content, err = feeds.Content(doc, entry.URL)
+	if err != nil {
+		return nil, fmt.Errorf("could not render the content of %s : %s", relPath, err.Error())
+	}
+
+	return entry, nil
+}
+
+// writeFeeds writes every configured feed into outputDir, after every page has been compiled.
+// it returns the files it wrote, relative to outputDir
+func writeFeeds(configs []*sklairConfig.Feed, siteURL string, inputDir string, outputDir string, pages []string, collections map[string]any, outputs []luaSandbox.OutputFile) ([]string, error) {
+	var written []string
+
+	// the source file of every compiled page, by its output path
+	sources := map[string]string{}
+	for _, out := range outputs {
+		if out.Kind == "html" || out.Kind == "markdown" {
+			sources[out.Output] = out.Source
+		}
+	}
+
+	for i, c := range configs {
+		name := c.Collection
+		if name == "" {
+			name = c.Source
+		}
+		errPrefix := fmt.Sprintf("feed %d (%s)", i+1, name)
+
+		var items []any
+		switch {
+		case c.Collection != "" && c.Source != "":
+			return nil, errors.New(errPrefix + " can only have either a collection or a source")
+		case c.Collection != "":
+			collection, ok := collections[c.Collection].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s uses the collection %s, which does not exist", errPrefix, c.Collection)
+			}
+			items, _ = collection["items"].([]any)
+		case c.Source != "":
+			var err error
+			items, err = sourceItems(inputDir, filepath.Join(inputDir, c.Source), pages)
+			if err != nil {
+				return nil, fmt.Errorf("%s : %s", errPrefix, err.Error())
+			}
+		default:
+			return nil, errors.New(errPrefix + " needs either a collection or a source")
+		}
+
+		if c.RSS == "" && c.Atom == "" && c.JSON == "" {
+			return nil, errors.New(errPrefix + " needs an output path for at least one of rss, atom and json")
+		}
+
+		feed := &feeds.Feed{
+			Title:       c.Title,
+			Description: c.Description,
+			Author:      c.Author,
+			HomeURL:     siteURL + "/",
+		}
+
+		for _, item := range items {
+			fields, ok := item.(map[string]any)
+			if !ok {
+				return nil, errors.New(errPrefix + " can only use collections of pages or data tables")
+			}
+
+			entry, err := feedEntry(inputDir, outputDir, siteURL, fields, c.Author, sources)
+			if err != nil {
+				return nil, fmt.Errorf("%s : %s", errPrefix, err.Error())
+			}
+			feed.Entries = append(feed.Entries, entry)
+		}
+
+		// newest first, entries without a date come last
+		sort.SliceStable(feed.Entries, func(a, b int) bool {
+			return feed.Entries[a].Published.After(feed.Entries[b].Published)
+		})
+
+		limit := c.Limit
+		if limit <= 0 {
+			limit = defaultFeedLimit
+		}
+		feed.Entries = feed.Entries[:min(limit, len(feed.Entries))]
+
+		// a feed without any dates is left without one too, so that building the same project twice gives the same feed
+		for _, e := range feed.Entries {
+			if e.Updated.After(feed.Updated) {
+				feed.Updated = e.Updated
+			}
+		}
+
+		formats := []struct {
+			path   string
+			render func(string) ([]byte, error)
+		}{
+			{c.RSS, feed.RSS},
+			{c.Atom, feed.Atom},
+			{c.JSON, feed.JSON},
+		}
+		for _, format := range formats {
+			if format.path == "" {
+				continue
+			}
+
+			relPath := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(format.path)), "/")
+			if relPath == "" || strings.HasSuffix(format.path, "/") {
+				return nil, fmt.Errorf("%s has the output path %q, which is not a file", errPrefix, format.path)
+			}
+
+			outPath := filepath.Join(outputDir, relPath)
+			if _, err := os.Stat(outPath); err == nil {
+				return nil, fmt.Errorf("%s would overwrite %s", errPrefix, relPath)
+			}
+
+			out, err := format.render(siteURL + (&url.URL{Path: "/" + relPath}).EscapedPath())
+			if err != nil {
+				return nil, fmt.Errorf("%s could not be rendered : %s", errPrefix, err.Error())
+			}
+
+			if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+				return nil, fmt.Errorf("could not create output directory for %s : %s", relPath, err.Error())
+			}
+			if err := os.WriteFile(outPath, out, 0644); err != nil {
+				return nil, fmt.Errorf("could not write %s : %s", relPath, err.Error())
+			}
+
+			written = append(written, relPath)
+		}
+	}
+
+	return written, nil
+}
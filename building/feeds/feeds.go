@@ -0,0 +1,457 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"sklair/building/frontMatter"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Entry is a single page in a feed, all urls are absolute
+type Entry struct {
+	URL       string
+	Title     string
+	Summary   string
+	Content   string // html
+	Published time.Time
+	Updated   time.Time // same as Published, unless the page declares when it was updated
+	Author    string
+	Tags      []string
+}
+
+// Feed holds everything that the RSS, Atom and JSON formats are generated from
+type Feed struct {
+	Title       string
+	Description string
+	Author      string
+	HomeURL     string
+	Updated     time.Time
+	Entries     []*Entry
+}
+
+// --------------------------------------------------
+// extracting entries from compiled pages
+// --------------------------------------------------
+
+// attributes that hold urls, which are made absolute so that links in feed readers keep working
+var urlAttributes = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"poster": true,
+}
+
+// skipped in the content of entries, they do not work in feed readers anyway
+var removedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+}
+
+func absoluteURL(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	u, err := url.Parse(ref)
+	if err != nil || ref == "" {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
+
+func absoluteSrcset(base *url.URL, srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = absoluteURL(base, fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// contentRoot is the element that holds the actual content of a page,
+// which is the first <article> or <main>, otherwise the whole <body>
+func contentRoot(doc *html.Node) *html.Node {
+	for _, tag := range []string{"article", "main", "body"} {
+		for n := range doc.Descendants() {
+			if n.Type == html.ElementNode && n.Data == tag {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// Content renders the content of a compiled page with every url made absolute against pageURL
+func Content(doc *html.Node, pageURL string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+
+	root := contentRoot(doc)
+	if root == nil {
+		return "", nil
+	}
+
+	var removed []*html.Node
+	for n := range root.Descendants() {
+		if n.Type == html.CommentNode || (n.Type == html.ElementNode && removedElements[n.Data]) {
+			removed = append(removed, n)
+			continue
+		}
+		if n.Type != html.ElementNode {
+			continue
+		}
+
+		for i, attr := range n.Attr {
+			switch {
+			case urlAttributes[attr.Key]:
+				n.Attr[i].Val = absoluteURL(base, attr.Val)
+			case attr.Key == "srcset":
+				n.Attr[i].Val = absoluteSrcset(base, attr.Val)
+			}
+		}
+	}
+	// removed afterwards, because removing while iterating would end the iteration early
+	for _, n := range removed {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+
+	out := bytes.NewBuffer(nil)
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		if err := html.Render(out, child); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// Meta returns the title, description and published time of a compiled page,
+// from its OpenGraph meta tags and otherwise from its <title> and description meta tag
+func Meta(doc *html.Node) (title string, description string, published time.Time) {
+	var docTitle, metaDescription string
+
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode {
+			continue
+		}
+
+		if n.Data == "title" && docTitle == "" && n.FirstChild != nil {
+			docTitle = strings.TrimSpace(n.FirstChild.Data)
+			continue
+		}
+		if n.Data != "meta" {
+			continue
+		}
+
+		var key, content string
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "name", "property":
+				key = strings.ToLower(attr.Val)
+			case "content":
+				content = attr.Val
+			}
+		}
+
+		switch key {
+		case "og:title":
+			title = content
+		case "og:description":
+			description = content
+		case "description":
+			metaDescription = content
+		case "article:published_time":
+			published, _ = frontMatter.ParseDate(content)
+		}
+	}
+
+	if title == "" {
+		title = docTitle
+	}
+	if description == "" {
+		description = metaDescription
+	}
+
+	return title, description, published
+}
+
+// --------------------------------------------------
+// RSS 2.0
+// --------------------------------------------------
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Author      string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description,omitempty"`
+	Content     string   `xml:"content:encoded,omitempty"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	Self          rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate,omitempty"`
+	Generator     string      `xml:"generator"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rss struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+func marshalXML(v any) ([]byte, error) {
+	out, err := xml.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// RSS renders the feed as RSS 2.0, selfURL is where the feed itself is published
+func (f *Feed) RSS(selfURL string) ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.HomeURL,
+		Description: f.Description,
+		Self:        rssAtomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+		Generator:   "Sklair",
+	}
+	if !f.Updated.IsZero() {
+		channel.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+
+	for _, e := range f.Entries {
+		item := rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			GUID:        rssGUID{IsPermaLink: true, Value: e.URL},
+			Author:      e.Author,
+			Categories:  e.Tags,
+			Description: e.Summary,
+			Content:     e.Content,
+		}
+		if !e.Published.IsZero() {
+			item.PubDate = e.Published.Format(time.RFC1123Z)
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	return marshalXML(rss{
+		Version:   "2.0",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		Channel:   channel,
+	})
+}
+
+// --------------------------------------------------
+// Atom
+// --------------------------------------------------
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Updated    string         `xml:"updated,omitempty"`
+	Published  string         `xml:"published,omitempty"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Xmlns     string      `xml:"xmlns,attr"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	ID        string      `xml:"id"`
+	Links     []atomLink  `xml:"link"`
+	Updated   string      `xml:"updated,omitempty"`
+	Author    *atomPerson `xml:"author,omitempty"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+// Atom renders the feed as Atom, selfURL is where the feed itself is published.
+// atom needs an updated time for everything, so entries without a date use the time of the feed.
+// it is only left out when no entry has a date at all
+func (f *Feed) Atom(selfURL string) ([]byte, error) {
+	feed := atomFeed{
+		Xmlns:    "http://www.w3.org/2005/Atom",
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.HomeURL,
+		Links: []atomLink{
+			{Href: f.HomeURL},
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+		},
+		Generator: "Sklair",
+	}
+	if !f.Updated.IsZero() {
+		feed.Updated = f.Updated.Format(time.RFC3339)
+	}
+	if f.Author != "" {
+		feed.Author = &atomPerson{Name: f.Author}
+	}
+
+	for _, e := range f.Entries {
+		updated := e.Updated
+		if updated.IsZero() {
+			updated = f.Updated
+		}
+
+		entry := atomEntry{
+			Title: e.Title,
+			ID:    e.URL,
+			Link:  atomLink{Href: e.URL, Rel: "alternate", Type: "text/html"},
+		}
+		if !updated.IsZero() {
+			entry.Updated = updated.Format(time.RFC3339)
+		}
+		if !e.Published.IsZero() {
+			entry.Published = e.Published.Format(time.RFC3339)
+		}
+		if e.Author != "" && e.Author != f.Author {
+			entry.Author = &atomPerson{Name: e.Author}
+		}
+		for _, tag := range e.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if e.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: e.Summary}
+		}
+		if e.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: e.Content}
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return marshalXML(feed)
+}
+
+// --------------------------------------------------
+// JSON Feed 1.1
+// --------------------------------------------------
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title,omitempty"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	DatePublished string       `json:"date_published,omitempty"`
+	DateModified  string       `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url"`
+	FeedURL     string       `json:"feed_url"`
+	Description string       `json:"description,omitempty"`
+	Authors     []jsonAuthor `json:"authors,omitempty"`
+	Items       []jsonItem   `json:"items"`
+}
+
+// JSON renders the feed as JSON Feed 1.1, selfURL is where the feed itself is published
+func (f *Feed) JSON(selfURL string) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     selfURL,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+	if f.Author != "" {
+		feed.Authors = []jsonAuthor{{Name: f.Author}}
+	}
+
+	for _, e := range f.Entries {
+		item := jsonItem{
+			ID:          e.URL,
+			URL:         e.URL,
+			Title:       e.Title,
+			ContentHTML: e.Content,
+			Summary:     e.Summary,
+			Tags:        e.Tags,
+		}
+		if !e.Published.IsZero() {
+			item.DatePublished = e.Published.Format(time.RFC3339)
+		}
+		if !e.Updated.IsZero() && !e.Updated.Equal(e.Published) {
+			item.DateModified = e.Updated.Format(time.RFC3339)
+		}
+		if e.Author != "" && e.Author != f.Author {
+			item.Authors = []jsonAuthor{{Name: e.Author}}
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	out, err := json.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
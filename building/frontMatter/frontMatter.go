@@ -0,0 +1,109 @@
+package frontMatter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter holds the variables declared at the top of a page, i.e. its title or layout
+type FrontMatter map[string]any
+
+const delimiter = "---"
+
+// Parse splits YAML front matter from the rest of the content.
+// front matter must start on the very first line with ---, and ends with the next line that only contains ---.
+// content without front matter is returned as-is, with an empty FrontMatter
+func Parse(content []byte) (FrontMatter, []byte, error) {
+	fm := FrontMatter{}
+
+	// a UTF-8 BOM would hide the opening delimiter
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	first, rest, found := bytes.Cut(content, []byte("\n"))
+	if !found || string(bytes.TrimRight(first, " \t\r")) != delimiter {
+		return fm, content, nil
+	}
+
+	offset := 0
+	for {
+		line, after, more := bytes.Cut(rest[offset:], []byte("\n"))
+		if string(bytes.TrimRight(line, " \t\r")) == delimiter {
+			if err := yaml.Unmarshal(rest[:offset], &fm); err != nil {
+				return nil, nil, fmt.Errorf("invalid front matter : %s", err.Error())
+			}
+			if fm == nil {
+				fm = FrontMatter{}
+			}
+
+			return fm, after, nil
+		}
+
+		if !more {
+			return nil, nil, errors.New("front matter is never closed with ---")
+		}
+		offset = len(rest) - len(after)
+	}
+}
+
+// CommentMarker starts a leading comment that holds the front matter of a html page, i.e.
+//
+//	<!-- sklair:front-matter
+//	layout: docs
+//	title: About us
+//	-->
+const CommentMarker = "sklair:front-matter"
+
+// ParseHTML works like Parse, but also accepts front matter inside of a leading comment that starts with CommentMarker,
+// which keeps the page valid html for editors and other tools
+func ParseHTML(content []byte) (FrontMatter, []byte, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+
+	trimmed := bytes.TrimLeft(content, " \t\r\n")
+	opening := []byte("<!--")
+	if !bytes.HasPrefix(trimmed, opening) || !bytes.HasPrefix(bytes.TrimLeft(trimmed[len(opening):], " \t"), []byte(CommentMarker)) {
+		return Parse(content)
+	}
+
+	inner, rest, found := bytes.Cut(trimmed[len(opening):], []byte("-->"))
+	if !found {
+		return nil, nil, errors.New("front matter comment is never closed with -->")
+	}
+	inner = bytes.TrimPrefix(bytes.TrimLeft(inner, " \t"), []byte(CommentMarker))
+
+	fm := FrontMatter{}
+	if err := yaml.Unmarshal(inner, &fm); err != nil {
+		return nil, nil, fmt.Errorf("invalid front matter : %s", err.Error())
+	}
+	if fm == nil {
+		fm = FrontMatter{}
+	}
+
+	return fm, rest, nil
+}
+
+// String returns a variable as a string, or "" if it does not exist or is not a string
+func (fm FrontMatter) String(key string) string {
+	s, _ := fm[key].(string)
+	return s
+}
+
+// ParseDate accepts the dates that front matter, data files and meta tags usually contain.
+// YAML already turns unquoted dates into a time.Time, while quoted ones and meta tags are strings
+func ParseDate(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
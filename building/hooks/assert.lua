@@ -0,0 +1,99 @@
+-- assertion library for sklair test-hooks
+-- the regular assert(v, message) still works, because this table is callable
+
+local function describe(v)
+    if type(v) == "string" then
+        return string.format("%q", v)
+    end
+    return tostring(v)
+end
+
+local function deepEqual(a, b)
+    if type(a) ~= "table" or type(b) ~= "table" then
+        return a == b
+    end
+
+    for k, v in pairs(a) do
+        if not deepEqual(v, b[k]) then
+            return false
+        end
+    end
+    for k in pairs(b) do
+        if a[k] == nil then
+            return false
+        end
+    end
+
+    return true
+end
+
+-- reports the error at the line of the test which called the assertion.
+-- in PUC Lua that would be level 3, but gopher-lua counts error() itself as a level,
+-- so 4 skips error, fail and the assertion and lands in the test function
+local function fail(message, default)
+    error(message or default, 4)
+end
+
+local base = assert
+
+assert = setmetatable({}, {
+    __call = function(_, v, message, ...)
+        return base(v, message, ...)
+    end
+})
+
+function assert.equal(actual, expected, message)
+    if actual ~= expected then
+        fail(message, "expected " .. describe(expected) .. ", got " .. describe(actual))
+    end
+end
+
+function assert.notEqual(actual, unexpected, message)
+    if actual == unexpected then
+        fail(message, "expected anything but " .. describe(unexpected))
+    end
+end
+
+function assert.deepEqual(actual, expected, message)
+    if not deepEqual(actual, expected) then
+        fail(message, "tables are not deeply equal")
+    end
+end
+
+function assert.truthy(v, message)
+    if not v then
+        fail(message, "expected a truthy value, got " .. describe(v))
+    end
+end
+
+function assert.falsy(v, message)
+    if v then
+        fail(message, "expected a falsy value, got " .. describe(v))
+    end
+end
+
+function assert.contains(haystack, needle, message)
+    if type(haystack) == "string" then
+        if not string.find(haystack, needle, 1, true) then
+            fail(message, "expected " .. describe(haystack) .. " to contain " .. describe(needle))
+        end
+        return
+    end
+
+    for _, v in pairs(haystack) do
+        if deepEqual(v, needle) then
+            return
+        end
+    end
+    fail(message, "expected table to contain " .. describe(needle))
+end
+
+function assert.errors(fn, pattern, message)
+    local ok, err = pcall(fn)
+    if ok then
+        fail(message, "expected an error, but none was raised")
+    end
+    if pattern and not string.find(tostring(err), pattern) then
+        fail(message, "expected error matching " .. describe(pattern) .. ", got " .. describe(tostring(err)))
+    end
+end
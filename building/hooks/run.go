@@ -3,56 +3,223 @@ package hooks
 import (
 	"fmt"
 	"path/filepath"
+	"sklair/discovery"
+	"sklair/logger"
 	"sklair/luaSandbox"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+type HookStatus string
+
+const (
+	HookStatusCompleted HookStatus = "completed" // ran until the end of the file
+	HookStatusExited    HookStatus = "exited"    // called os.exit(0)
+	HookStatusFailed    HookStatus = "failed"    // raised an error or called os.exit() with a non-zero code
+	HookStatusAborted   HookStatus = "aborted"   // called sklair.abort()
+	HookStatusSkipped   HookStatus = "skipped"   // never ran, because an earlier hook called sklair.skipRemaining() or failed
 )
 
-func RunHooks(hooksDir string, hooks []string, ctx *luaSandbox.FSContext) error {
-	which := "pre"
-	if ctx.Mode == luaSandbox.HookModePost {
-		which = "post"
+type HookResult struct {
+	Name     string
+	Status   HookStatus
+	ExitCode int
+	Duration time.Duration
+
+	Warnings int
+	Errors   int
+}
+
+// Report describes what happened to every hook of a single phase
+type Report struct {
+	Phase   string
+	Results []*HookResult
+}
+
+func (r *Report) Count(status HookStatus) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Warnings returns the total number of warnings logged by hooks using log.warn()
+func (r *Report) Warnings() int {
+	n := 0
+	for _, res := range r.Results {
+		n += res.Warnings
 	}
+	return n
+}
 
-	hookDir := filepath.Join(hooksDir, which)
+// Errors returns the total number of errors logged by hooks using log.error()
+func (r *Report) Errors() int {
+	n := 0
+	for _, res := range r.Results {
+		n += res.Errors
+	}
+	return n
+}
 
-	exitChannel := make(chan int)
+// Log prints a line for each hook at the given level, i.e. logger.Info or logger.Debug
+func (r *Report) Log(log func(format string, args ...any)) {
+	for _, res := range r.Results {
+		switch res.Status {
+		case HookStatusSkipped:
+			log("%s/%s : %s", r.Phase, res.Name, res.Status)
+		case HookStatusExited, HookStatusFailed:
+			log("%s/%s : %s with code %d after %s", r.Phase, res.Name, res.Status, res.ExitCode, res.Duration)
+		default:
+			log("%s/%s : %s in %s", r.Phase, res.Name, res.Status, res.Duration)
+		}
+	}
+}
 
-	for _, hookFilename := range hooks {
-		sourcePath := filepath.Join(hookDir, hookFilename)
+// phaseName is the directory that the hooks of a phase are in
+func phaseName(mode luaSandbox.HookMode) string {
+	switch mode {
+	case luaSandbox.HookModePost:
+		return "post"
+	case luaSandbox.HookModePage:
+		return "page"
+	default:
+		return "pre"
+	}
+}
 
-		exitChannel = make(chan int, 1)
-		done := make(chan error, 1)
+// compileHook reads and compiles a hook without running it.
+// errors are the same as the ones from L.DoFile, so that the build can still find the file and line of syntax errors
+func compileHook(hookDir string, hook *discovery.Hook) (*lua.FunctionProto, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer L.Close()
 
-		L := luaSandbox.NewSandbox(luaSandbox.SandboxOptions{
-			ExitChannel: exitChannel,
-			FSContext:   *ctx,
-		})
+	fn, err := L.LoadFile(filepath.Join(hookDir, hook.Name))
+	if err != nil {
+		return nil, err
+	}
+	return fn.Proto, nil
+}
 
-		// lua must run asynchronously, otherwise we cannot track when the exit channel was used with os.exit()
-		go func() {
-			done <- L.DoFile(sourcePath)
-		}()
+// CompiledHooks are the hooks of a phase, read and compiled once so that they can be run for every page of a build
+type CompiledHooks struct {
+	mode   luaSandbox.HookMode
+	hooks  []*discovery.Hook
+	protos []*lua.FunctionProto
+}
 
-		select {
-		case code := <-exitChannel:
-			// lua called os.exit()
-			L.Close()
+// CompileHooks reads and compiles the given hooks of the phase that mode belongs to
+func CompileHooks(hooksDir string, mode luaSandbox.HookMode, hooks []*discovery.Hook) (*CompiledHooks, error) {
+	hookDir := filepath.Join(hooksDir, phaseName(mode))
+	compiled := &CompiledHooks{mode: mode, hooks: hooks, protos: make([]*lua.FunctionProto, len(hooks))}
 
-			switch code {
-			case 0:
-				return nil
-			case 1:
-				return fmt.Errorf("hook %s exited with failure", hookFilename)
-			default:
-				return fmt.Errorf("hook %s exited with code %d", hookFilename, code)
+	for i, hook := range hooks {
+		proto, err := compileHook(hookDir, hook)
+		if err != nil {
+			return nil, fmt.Errorf("hook %s failed\n%w", hook.Name, err)
+		}
+		compiled.protos[i] = proto
+	}
+
+	return compiled, nil
+}
+
+// Run runs the compiled hooks like RunHooks does, opts.FSContext.Mode must be the mode they were compiled for
+func (c *CompiledHooks) Run(opts luaSandbox.SandboxOptions) (*Report, error) {
+	if opts.FSContext.Mode != c.mode {
+		return &Report{Phase: phaseName(opts.FSContext.Mode)}, fmt.Errorf("%s hooks can not run as %s hooks", phaseName(c.mode), phaseName(opts.FSContext.Mode))
+	}
+
+	return runHooks(c.hooks, opts, func(i int) (*lua.FunctionProto, error) {
+		return c.protos[i], nil
+	})
+}
+
+// RunHooks runs the given hooks one after another, each in its own sandbox created from opts.
+// The permissions declared for each hook in hooks.json are applied on top of opts.
+//
+// A hook ends when it reaches the end of its file or calls os.exit().
+// os.exit(0) only ends the current hook, while a non-zero exit code, an error or sklair.abort() fails the whole phase.
+// sklair.skipRemaining() lets the current hook finish but skips every hook after it.
+//
+// The returned report is always non-nil, even when an error is returned.
+func RunHooks(hooksDir string, hooks []*discovery.Hook, opts luaSandbox.SandboxOptions) (*Report, error) {
+	// every hook is only read once it is its turn, so that the ones before it still run if it does not compile
+	hookDir := filepath.Join(hooksDir, phaseName(opts.FSContext.Mode))
+	return runHooks(hooks, opts, func(i int) (*lua.FunctionProto, error) {
+		return compileHook(hookDir, hooks[i])
+	})
+}
+
+func runHooks(hooks []*discovery.Hook, opts luaSandbox.SandboxOptions, proto func(i int) (*lua.FunctionProto, error)) (*Report, error) {
+	which := phaseName(opts.FSContext.Mode)
+	report := &Report{Phase: which}
+
+	var stopErr error
+	skipping := false
+
+	for i, hook := range hooks {
+		result := &HookResult{Name: hook.Name, Status: HookStatusSkipped}
+		report.Results = append(report.Results, result)
+
+		if skipping || stopErr != nil {
+			continue
+		}
+
+		control := &luaSandbox.HookControl{}
+
+		hookOpts := opts
+		hookOpts.Control = control
+		hookOpts.HookName = which + "/" + hook.Name
+		if hook.Permissions != nil {
+			if hook.Permissions.FS != nil {
+				hookOpts.FSContext.AllowedRoots = hook.Permissions.FS
 			}
+			if hook.Permissions.HTTP != nil {
+				hookOpts.HttpContext.RestrictHosts(hook.Permissions.HTTP)
+			}
+		}
+
+		start := time.Now()
 
-		case err := <-done:
+		fnProto, err := proto(i)
+		if err == nil {
+			L := luaSandbox.NewSandbox(hookOpts)
+			L.Push(L.NewFunctionFromProto(fnProto))
+			err = L.PCall(0, lua.MultRet, nil)
 			L.Close()
-			if err != nil {
-				return fmt.Errorf("hook %s failed\n%s", hookFilename, err.Error())
-			}
+		}
+
+		result.Duration = time.Since(start)
+		result.ExitCode = control.ExitCode
+		result.Warnings = control.Warnings
+		result.Errors = control.Errors
+
+		// the control state is checked before err, because os.exit() and sklair.abort() unwind the hook by raising an error
+		switch {
+		case control.Aborted:
+			result.Status = HookStatusAborted
+			stopErr = fmt.Errorf("hook %s aborted the build : %s", hook.Name, control.AbortMessage)
+		case control.Exited && control.ExitCode == 0:
+			result.Status = HookStatusExited
+		case control.Exited:
+			result.Status = HookStatusFailed
+			stopErr = fmt.Errorf("hook %s exited with code %d", hook.Name, control.ExitCode)
+		case err != nil:
+			result.Status = HookStatusFailed
+			stopErr = fmt.Errorf("hook %s failed\n%w", hook.Name, err) // wrapped, so that the build can find the file and line of the error
+		default:
+			result.Status = HookStatusCompleted
+		}
+
+		if control.SkipRemaining {
+			logger.Debug("%s/%s requested that the remaining hooks are skipped", which, hook.Name)
+			skipping = true
 		}
 	}
 
-	return nil
+	return report, stopErr
 }
@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTAP writes the results in the Test Anything Protocol (version 13) format
+func WriteTAP(w io.Writer, cases []*TestCase) error {
+	var sb strings.Builder
+
+	sb.WriteString("TAP version 13\n")
+	fmt.Fprintf(&sb, "1..%d\n", len(cases))
+
+	for i, tc := range cases {
+		status := "ok"
+		if !tc.Passed {
+			status = "not ok"
+		}
+		fmt.Fprintf(&sb, "%s %d - %s : %s\n", status, i+1, tc.File, tc.Name)
+
+		if !tc.Passed {
+			sb.WriteString("  ---\n")
+			sb.WriteString("  message: |\n")
+			for _, line := range strings.Split(tc.Message, "\n") {
+				sb.WriteString("    " + line + "\n")
+			}
+			fmt.Fprintf(&sb, "  duration_ms: %d\n", tc.Duration.Milliseconds())
+			sb.WriteString("  ...\n")
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",cdata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Time      string           `xml:"time,attr"`
+	TestCases []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+// WriteJUnit writes the results as JUnit XML, with one test suite per test file
+func WriteJUnit(w io.Writer, cases []*TestCase) error {
+	out := &junitTestSuites{}
+
+	suites := make(map[string]*junitTestSuite)
+	durations := make(map[string]float64)
+	for _, tc := range cases {
+		suite, ok := suites[tc.File]
+		if !ok {
+			suite = &junitTestSuite{Name: tc.File}
+			suites[tc.File] = suite
+			out.Suites = append(out.Suites, suite)
+		}
+
+		jtc := &junitTestCase{
+			Name:      tc.Name,
+			ClassName: tc.File,
+			Time:      fmt.Sprintf("%.3f", tc.Duration.Seconds()),
+		}
+		if !tc.Passed {
+			firstLine, _, _ := strings.Cut(tc.Message, "\n")
+			jtc.Failure = &junitFailure{Message: firstLine, Body: tc.Message}
+			suite.Failures++
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, jtc)
+		durations[tc.File] += tc.Duration.Seconds()
+	}
+
+	for _, suite := range out.Suites {
+		suite.Time = fmt.Sprintf("%.3f", durations[suite.Name])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
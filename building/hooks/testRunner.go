@@ -0,0 +1,594 @@
+package hooks
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sklair/discovery"
+	"sklair/luaSandbox"
+	"sklair/sklairConfig"
+	"sklair/util"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"golang.org/x/net/html"
+	luaJson "layeh.com/gopher-json"
+)
+
+//go:embed assert.lua
+var assertLib string
+
+type TestCase struct {
+	File     string // relative to the hooks directory, slash-separated
+	Name     string
+	Passed   bool
+	Message  string // failure message, empty if passed
+	Duration time.Duration
+}
+
+// FindTests returns every *_test.lua file inside hooksDir, relative to it
+func FindTests(hooksDir string) ([]string, error) {
+	var tests []string
+
+	err := filepath.WalkDir(hooksDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "fixtures" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), discovery.TestSuffix) {
+			rel, err := filepath.Rel(hooksDir, path)
+			if err != nil {
+				return err
+			}
+			tests = append(tests, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(tests)
+	return tests, nil
+}
+
+// --------------------------------------------------
+// mock http
+// --------------------------------------------------
+
+type mockResponse struct {
+	status  int
+	headers http.Header
+	body    string
+}
+
+type mockRequest struct {
+	method string
+	url    string
+	body   string
+}
+
+// mockTransport answers requests made by hooks from responses registered with http.mock(),
+// so that tests never touch the network
+type mockTransport struct {
+	mu        sync.Mutex
+	responses map[string]*mockResponse // "METHOD url" -> response
+	requests  []mockRequest
+}
+
+func (m *mockTransport) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.responses = make(map[string]*mockResponse)
+	m.requests = nil
+}
+
+func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, mockRequest{req.Method, req.URL.String(), string(body)})
+
+	resp, ok := m.responses[req.Method+" "+req.URL.String()]
+	if !ok {
+		return nil, fmt.Errorf("no mock registered for %s %s", req.Method, req.URL.String())
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(resp.status),
+		StatusCode: resp.status,
+		Header:     resp.headers.Clone(),
+		Body:       io.NopCloser(strings.NewReader(resp.body)),
+		Request:    req,
+	}, nil
+}
+
+// --------------------------------------------------
+// runner
+// --------------------------------------------------
+
+type testRun struct {
+	hooksDir string
+	file     string // relative to hooksDir
+	config   *sklairConfig.ProjectConfig
+
+	roots map[string]string // fs root name -> throwaway directory
+	mock  *mockTransport
+}
+
+func (t *testRun) fsContext(mode luaSandbox.HookMode) luaSandbox.FSContext {
+	return luaSandbox.FSContext{
+		CacheDir:     t.roots["cache"],
+		ProjectDir:   t.roots["project"],
+		TempDir:      t.roots["temp"],
+		GeneratedDir: t.roots["generated"],
+		BuiltDir:     t.roots["built"],
+		Mode:         mode,
+	}
+}
+
+func (t *testRun) httpContext(mode luaSandbox.HookMode) luaSandbox.HttpContext {
+	var options *sklairConfig.HooksHttpOptions
+	if t.config.Hooks != nil {
+		options = t.config.Hooks.Http
+	}
+
+	ctx := luaSandbox.HttpContextFromConfig(options, mode)
+	ctx.Transport = t.mock
+	return ctx
+}
+
+// resetRoots empties every throwaway directory, so that each test case starts from a clean slate
+func (t *testRun) resetRoots() error {
+	for _, dir := range t.roots {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve turns "root:relative/path" into a path inside the throwaway directories.
+// unlike the fs library, every root (including project) is writable, so that tests can set up files.
+func (t *testRun) resolve(path string) (string, error) {
+	root, rel, found := strings.Cut(path, ":")
+	dir, ok := t.roots[root]
+	if !found || !ok {
+		return "", fmt.Errorf("path must start with one of %v, followed by a colon and a relative path", discovery.FSRoots)
+	}
+	if strings.Contains(rel, "..") {
+		return "", errors.New("path traversal is not allowed")
+	}
+	return filepath.Join(dir, rel), nil
+}
+
+func (t *testRun) buildInfo(profile sklairConfig.Profile) (*luaSandbox.BuildInfo, error) {
+	info := &luaSandbox.BuildInfo{
+		Config:     t.config,
+		Profile:    profile,
+		Components: map[string]string{},
+		Data:       map[string]any{},
+	}
+
+	scanned, err := discovery.DiscoverDocuments(t.roots["project"], nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range scanned.HtmlFiles {
+		rel, _ := filepath.Rel(t.roots["project"], f)
+		info.HtmlFiles = append(info.HtmlFiles, filepath.ToSlash(rel))
+	}
+	for _, f := range scanned.StaticFiles {
+		rel, _ := filepath.Rel(t.roots["project"], f)
+		info.StaticFiles = append(info.StaticFiles, filepath.ToSlash(rel))
+	}
+
+	// whatever the test put into built: is treated as the output of the build
+	_ = filepath.WalkDir(t.roots["built"], func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(t.roots["built"], path)
+		rel = filepath.ToSlash(rel)
+
+		kind := "static"
+		if strings.HasSuffix(rel, ".html") {
+			kind = "html"
+		}
+		info.Outputs = append(info.Outputs, luaSandbox.OutputFile{Source: rel, Output: rel, Kind: kind})
+		return nil
+	})
+
+	return info, nil
+}
+
+// RunTests runs every test file in hooksDir and returns the results of every test case
+func RunTests(hooksDir string, config *sklairConfig.ProjectConfig) ([]*TestCase, error) {
+	files, err := FindTests(hooksDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []*TestCase
+	for _, file := range files {
+		results, err := runTestFile(hooksDir, file, config)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, results...)
+	}
+
+	return cases, nil
+}
+
+func runTestFile(hooksDir string, file string, config *sklairConfig.ProjectConfig) ([]*TestCase, error) {
+	tmp, err := os.MkdirTemp("", "sklair-hooktest-")
+	if err != nil {
+		return nil, fmt.Errorf("could not create temporary directory : %s", err.Error())
+	}
+	defer os.RemoveAll(tmp)
+
+	t := &testRun{
+		hooksDir: hooksDir,
+		file:     file,
+		config:   config,
+		roots:    make(map[string]string, len(discovery.FSRoots)),
+		mock:     &mockTransport{},
+	}
+	for _, root := range discovery.FSRoots {
+		t.roots[root] = filepath.Join(tmp, root)
+	}
+	if err := t.resetRoots(); err != nil {
+		return nil, err
+	}
+	t.mock.reset()
+
+	L, tests, err := t.load()
+	if err != nil {
+		return []*TestCase{{File: file, Name: "(load)", Message: err.Error()}}, nil
+	}
+	L.Close()
+
+	// every test gets a sandbox of its own, so that os.exit() or sklair.abort() in one test does not end the ones after it.
+	// the file is loaded again for each of them, which also resets any state that they would otherwise share
+	var cases []*TestCase
+	for i, test := range tests {
+		L, fresh, err := t.load()
+		if err == nil && len(fresh) != len(tests) {
+			err = errors.New("the file registered a different number of tests when it was loaded again")
+		}
+		if err != nil {
+			cases = append(cases, &TestCase{File: file, Name: test.name, Message: err.Error()})
+			continue
+		}
+
+		if err := t.resetRoots(); err != nil {
+			L.Close()
+			return nil, err
+		}
+		t.mock.reset()
+
+		start := time.Now()
+		L.Push(fresh[i].fn)
+		err = L.PCall(0, 0, nil)
+		L.Close()
+
+		tc := &TestCase{File: file, Name: test.name, Passed: err == nil, Duration: time.Since(start)}
+		if err != nil {
+			tc.Message = err.Error()
+
+			// the stack trace mostly consists of the assertion library itself, so only keep the message
+			var apiErr *lua.ApiError
+			if errors.As(err, &apiErr) && apiErr.Object != nil {
+				tc.Message = apiErr.Object.String()
+			}
+		}
+		cases = append(cases, tc)
+	}
+
+	return cases, nil
+}
+
+type registeredTest struct {
+	name string
+	fn   *lua.LFunction
+}
+
+// load creates a new sandbox and runs the test file in it, which registers its tests.
+// the test itself behaves like a post-build hook, so that it can inspect built: files
+func (t *testRun) load() (*lua.LState, []registeredTest, error) {
+	L := luaSandbox.NewSandbox(luaSandbox.SandboxOptions{
+		HookName:    t.file,
+		FSContext:   t.fsContext(luaSandbox.HookModePost),
+		HttpContext: t.httpContext(luaSandbox.HookModePost),
+	})
+
+	var tests []registeredTest
+	L.SetGlobal("test", L.NewFunction(func(L *lua.LState) int {
+		tests = append(tests, registeredTest{L.CheckString(1), L.CheckFunction(2)})
+		return 0
+	}))
+	L.SetGlobal("runHook", L.NewFunction(t.luaRunHook))
+	L.SetGlobal("fixtures", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"load":  t.luaLoadFixture,
+		"write": t.luaWriteFixture,
+		"read":  t.luaReadFixture,
+	}))
+	if mod, ok := L.GetGlobal("http").(*lua.LTable); ok {
+		L.SetFuncs(mod, map[string]lua.LGFunction{
+			"mock":     t.luaMock,
+			"requests": t.luaRequests,
+		})
+	}
+
+	if err := L.DoString(assertLib); err != nil {
+		L.Close()
+		return nil, nil, fmt.Errorf("could not load assertion library : %s", err.Error())
+	}
+
+	if err := L.DoFile(filepath.Join(t.hooksDir, t.file)); err != nil {
+		L.Close()
+		return nil, nil, err
+	}
+
+	return L, tests, nil
+}
+
+// luaRunHook runs a hook (e.g. "pre/1-hello.lua") against the throwaway directories.
+// The optional second argument is a table with the fields profile, data (what the hook sees as sklair.data)
+// and page = { path, html }, the latter only for page hooks.
+// Returns a table with status, exitCode, warnings, errors, error (if any) and html (for page hooks).
+func (t *testRun) luaRunHook(L *lua.LState) int {
+	name := L.CheckString(1)
+	options := L.OptTable(2, L.NewTable())
+
+	phase, hookName, _ := strings.Cut(name, "/")
+	var mode luaSandbox.HookMode
+	switch phase {
+	case "pre":
+		mode = luaSandbox.HookModePre
+	case "post":
+		mode = luaSandbox.HookModePost
+	case "page":
+		mode = luaSandbox.HookModePage
+	default:
+		L.ArgError(1, "hook must be in the form pre/name.lua, post/name.lua or page/name.lua")
+	}
+
+	profile := sklairConfig.ProfileProduction
+	if p, ok := options.RawGetString("profile").(lua.LString); ok {
+		profile = sklairConfig.Profile(p)
+	}
+
+	// permissions from hooks.json apply, just like in a real build
+	set, err := discovery.DiscoverHooks(t.hooksDir, profile)
+	if err != nil {
+		L.RaiseError("could not discover hooks : %s", err.Error())
+	}
+
+	var phaseHooks []*discovery.Hook
+	switch mode {
+	case luaSandbox.HookModePre:
+		phaseHooks = set.PreBuild
+	case luaSandbox.HookModePost:
+		phaseHooks = set.PostBuild
+	case luaSandbox.HookModePage:
+		phaseHooks = set.Page
+	}
+
+	hook := &discovery.Hook{Name: hookName}
+	for _, h := range phaseHooks {
+		if h.Name == hookName {
+			hook = h
+			break
+		}
+	}
+
+	info, err := t.buildInfo(profile)
+	if err != nil {
+		L.RaiseError("could not index fixtures : %s", err.Error())
+	}
+
+	if data, ok := options.RawGetString("data").(*lua.LTable); ok {
+		encoded, err := luaJson.Encode(data)
+		if err != nil {
+			L.ArgError(2, "data must be encodable as json : "+err.Error())
+		}
+		// an empty table is encoded as an empty json array, which simply leaves the data empty
+		if string(encoded) != "[]" {
+			if err := json.Unmarshal(encoded, &info.Data); err != nil {
+				L.ArgError(2, "data must be a table with string keys")
+			}
+		}
+	}
+
+	opts := luaSandbox.SandboxOptions{
+		FSContext:   t.fsContext(mode),
+		HttpContext: t.httpContext(mode),
+		BuildInfo:   info,
+	}
+
+	if page, ok := options.RawGetString("page").(*lua.LTable); ok {
+		if mode != luaSandbox.HookModePage {
+			L.ArgError(2, "page can only be given to page hooks")
+		}
+
+		path, ok := page.RawGetString("path").(lua.LString)
+		if !ok {
+			L.ArgError(2, "page.path must be a string")
+		}
+		source, ok := page.RawGetString("html").(lua.LString)
+		if !ok {
+			L.ArgError(2, "page.html must be a string")
+		}
+
+		doc, err := html.Parse(strings.NewReader(string(source)))
+		if err != nil {
+			L.ArgError(2, "could not parse page html : "+err.Error())
+		}
+		opts.Page = &luaSandbox.PageContext{Path: string(path), Document: doc}
+	} else if mode == luaSandbox.HookModePage {
+		L.ArgError(2, "page hooks need a page = { path = ..., html = ... } option")
+	}
+
+	report, err := RunHooks(t.hooksDir, []*discovery.Hook{hook}, opts)
+	res := report.Results[0]
+
+	out := L.NewTable()
+	out.RawSetString("status", lua.LString(res.Status))
+	out.RawSetString("exitCode", lua.LNumber(res.ExitCode))
+	out.RawSetString("warnings", lua.LNumber(res.Warnings))
+	out.RawSetString("errors", lua.LNumber(res.Errors))
+	if err != nil {
+		out.RawSetString("error", lua.LString(err.Error()))
+	}
+	if opts.Page != nil {
+		buf := bytes.NewBuffer(nil)
+		if err := html.Render(buf, opts.Page.Document); err == nil {
+			out.RawSetString("html", lua.LString(buf.String()))
+		}
+	}
+
+	L.Push(out)
+	return 1
+}
+
+// luaLoadFixture copies fixtures/<name>/<root>/ (next to the test file) into the matching throwaway directories
+func (t *testRun) luaLoadFixture(L *lua.LState) int {
+	name := L.CheckString(1)
+	if strings.Contains(name, "..") {
+		L.ArgError(1, "path traversal is not allowed")
+	}
+
+	fixtureDir := filepath.Join(t.hooksDir, filepath.Dir(t.file), "fixtures", name)
+	if _, err := os.Stat(fixtureDir); err != nil {
+		L.RaiseError("fixture %q does not exist : %s", name, err.Error())
+	}
+
+	for root, dir := range t.roots {
+		src := filepath.Join(fixtureDir, root)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := util.CopyDir(src, dir); err != nil {
+			L.RaiseError("could not load fixture %q : %s", name, err.Error())
+		}
+	}
+
+	return 0
+}
+
+func (t *testRun) luaWriteFixture(L *lua.LState) int {
+	path, err := t.resolve(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	if err := os.WriteFile(path, []byte(L.CheckString(2)), 0644); err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+
+	return 0
+}
+
+// luaReadFixture returns the contents of a file, or nil if it does not exist
+func (t *testRun) luaReadFixture(L *lua.LState) int {
+	path, err := t.resolve(L.CheckString(1))
+	if err != nil {
+		L.ArgError(1, err.Error())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	L.Push(lua.LString(data))
+	return 1
+}
+
+// luaMock registers a response: http.mock{ method = "GET", url = "...", status = 200, headers = {}, body = "" },
+// where every header is either a string or a list of strings
+func (t *testRun) luaMock(L *lua.LState) int {
+	spec := L.CheckTable(1)
+
+	url, ok := spec.RawGetString("url").(lua.LString)
+	if !ok {
+		L.ArgError(1, "url must be a string")
+	}
+
+	method := "GET"
+	if m, ok := spec.RawGetString("method").(lua.LString); ok {
+		method = strings.ToUpper(string(m))
+	}
+
+	resp := &mockResponse{status: 200, headers: http.Header{}}
+	if s, ok := spec.RawGetString("status").(lua.LNumber); ok {
+		resp.status = int(s)
+	}
+	if b, ok := spec.RawGetString("body").(lua.LString); ok {
+		resp.body = string(b)
+	}
+	if h, ok := spec.RawGetString("headers").(*lua.LTable); ok {
+		// a list sends the header more than once, e.g. ["set-cookie"] = { "a=1", "b=2" }
+		h.ForEach(func(k, v lua.LValue) {
+			if list, ok := v.(*lua.LTable); ok {
+				list.ForEach(func(_, item lua.LValue) {
+					resp.headers.Add(k.String(), item.String())
+				})
+				return
+			}
+			resp.headers.Add(k.String(), v.String())
+		})
+	}
+
+	t.mock.mu.Lock()
+	t.mock.responses[method+" "+string(url)] = resp
+	t.mock.mu.Unlock()
+
+	return 0
+}
+
+// luaRequests returns every request made during the current test, as { method, url, body } tables
+func (t *testRun) luaRequests(L *lua.LState) int {
+	t.mock.mu.Lock()
+	defer t.mock.mu.Unlock()
+
+	table := L.CreateTable(len(t.mock.requests), 0)
+	for i, req := range t.mock.requests {
+		obj := L.NewTable()
+		obj.RawSetString("method", lua.LString(req.method))
+		obj.RawSetString("url", lua.LString(req.url))
+		obj.RawSetString("body", lua.LString(req.body))
+		table.RawSetInt(i+1, obj)
+	}
+
+	L.Push(table)
+	return 1
+}
@@ -0,0 +1,120 @@
+package building
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sklair/building/frontMatter"
+	"sklair/htmlUtilities"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ContentSlot is the comment in a layout that is replaced by the content of the page
+const ContentSlot = "sklair:content"
+
+const bareLayout = "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n</head>\n<body>\n<!-- sklair:content -->\n</body>\n</html>\n"
+
+func findContentSlot(n *html.Node) *html.Node {
+	for node := range n.Descendants() {
+		if node.Type == html.CommentNode && strings.TrimSpace(node.Data) == ContentSlot {
+			return node
+		}
+	}
+	return nil
+}
+
+// loadLayout reads the source of a layout component.
+// an empty name results in a bare document, which only consists of the content slot
+func loadLayout(componentsDir string, components map[string]string, name string) ([]byte, error) {
+	if name == "" {
+		return []byte(bareLayout), nil
+	}
+
+	fileName, ok := components[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("layout %s does not exist in the components directory", name)
+	}
+
+	return os.ReadFile(filepath.Join(componentsDir, fileName))
+}
+
+// applyLayout appends headNodes to the head of the layout and puts bodyNodes into its content slot.
+// the layout is parsed as a regular document, so it may use components just like any page
+func applyLayout(layout []byte, layoutName string, headNodes []*html.Node, bodyNodes []*html.Node) (*html.Node, error) {
+	doc, err := html.Parse(bytes.NewReader(layout))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse layout %s : %s", layoutName, err.Error())
+	}
+
+	slot := findContentSlot(doc)
+	if slot == nil {
+		return nil, fmt.Errorf("layout %s has no <!-- %s --> comment", layoutName, ContentSlot)
+	}
+
+	head := htmlUtilities.FindTag(doc, "head")
+	if head == nil {
+		return nil, fmt.Errorf("could not find the head of layout %s", layoutName)
+	}
+
+	htmlUtilities.AppendNodes(head, headNodes)
+	htmlUtilities.InsertNodesBefore(slot, bodyNodes)
+	slot.Parent.RemoveChild(slot)
+
+	return doc, nil
+}
+
+// setTitle adds a <title> to the head of doc, unless it already has one
+func setTitle(doc *html.Node, title string) {
+	head := htmlUtilities.FindTag(doc, "head")
+	if title == "" || head == nil || htmlUtilities.FindTag(head, "title") != nil {
+		return
+	}
+
+	titleNode := &html.Node{Type: html.ElementNode, Data: "title", DataAtom: atom.Title}
+	titleNode.AppendChild(&html.Node{Type: html.TextNode, Data: title})
+	head.AppendChild(titleNode)
+}
+
+// htmlDocument parses a html page. if its front matter declares a layout,
+// the head and body of the page are moved into the layout, and the layout becomes the document
+func htmlDocument(content []byte, componentsDir string, components map[string]string) (*html.Node, frontMatter.FrontMatter, error) {
+	fm, body, err := frontMatter.ParseHTML(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not parse file : %s", err.Error())
+	}
+
+	layoutName := fm.String("layout")
+	if layoutName == "" {
+		return doc, fm, nil
+	}
+
+	layout, err := loadLayout(componentsDir, components, layoutName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var headNodes, bodyNodes []*html.Node
+	if head := htmlUtilities.FindTag(doc, "head"); head != nil {
+		headNodes = htmlUtilities.GetAllChildren(head)
+	}
+	if b := htmlUtilities.FindTag(doc, "body"); b != nil {
+		bodyNodes = htmlUtilities.GetAllChildren(b)
+	}
+
+	doc, err = applyLayout(layout, layoutName, headNodes, bodyNodes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	setTitle(doc, fm.String("title"))
+	return doc, fm, nil
+}
@@ -0,0 +1,90 @@
+package building
+
+import (
+	"bytes"
+	"errors"
+	"sklair/building/frontMatter"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkHtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// headingAnchors appends a link to itself to every heading, i.e. <h2 id="setup">Setup<a class="heading-anchor" href="#setup">#</a></h2>.
+// the ids are generated by the parser before any transformers run
+type headingAnchors struct{}
+
+func (headingAnchors) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		id, ok := heading.AttributeString("id")
+		if !ok {
+			return ast.WalkSkipChildren, nil
+		}
+
+		link := ast.NewLink()
+		link.Destination = append([]byte("#"), id.([]byte)...)
+		link.SetAttributeString("class", []byte("heading-anchor"))
+		link.AppendChild(link, ast.NewString([]byte("#")))
+		heading.AppendChild(heading, link)
+
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// raw html is allowed, so that components can be used inside of Markdown
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.Footnote),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(util.Prioritized(headingAnchors{}, 1000)),
+	),
+	goldmark.WithRendererOptions(goldmarkHtml.WithUnsafe()),
+)
+
+// markdownDocument renders a Markdown page and wraps it in its layout.
+// the result is a regular html document, which is compiled like any other page
+func markdownDocument(content []byte, defaultLayout string, componentsDir string, components map[string]string) (*html.Node, frontMatter.FrontMatter, error) {
+	fm, body, err := frontMatter.Parse(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rendered := bytes.NewBuffer(nil)
+	if err := markdownRenderer.Convert(body, rendered); err != nil {
+		return nil, nil, errors.New("could not render Markdown : " + err.Error())
+	}
+
+	nodes, err := html.ParseFragment(rendered, &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layoutName := defaultLayout
+	if _, ok := fm["layout"]; ok {
+		layoutName = fm.String("layout") // layout: "" opts out of the default layout
+	}
+
+	layout, err := loadLayout(componentsDir, components, layoutName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := applyLayout(layout, layoutName, nil, nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	setTitle(doc, fm.String("title"))
+	return doc, fm, nil
+}
@@ -0,0 +1,105 @@
+package building
+
+import (
+	"net/url"
+	"path/filepath"
+	"sklair/linkChecker"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// isNotFoundPage reports whether relPath is a 404 page, which is served for missing pages at any depth (see devserver.try404),
+// so relative urls inside of it only work when the missing page happens to be at the root
+func isNotFoundPage(relPath string) bool {
+	p := strings.ToLower(filepath.ToSlash(relPath))
+	return p == "404.html" || p == "404.shtml"
+}
+
+// hasAnchoredBase reports whether doc has a <base> with a root-anchored or absolute href, which makes relative urls safe
+func hasAnchoredBase(base string) bool {
+	return base != "" && !linkChecker.IsRelative(base)
+}
+
+// rootAnchorURLs rewrites every relative url in doc to a root-anchored one, as resolved from the location of the page itself.
+// it returns how many urls were rewritten
+func rootAnchorURLs(doc *html.Node, relPath string) int {
+	page := &url.URL{Path: "/" + filepath.ToSlash(relPath)}
+	rewritten := 0
+
+	anchor := func(u string) string {
+		if !linkChecker.IsRelative(u) {
+			return u
+		}
+		ref, err := url.Parse(strings.TrimSpace(u))
+		if err != nil {
+			return u
+		}
+		rewritten++
+		return page.ResolveReference(ref).String()
+	}
+
+	for n := range doc.Descendants() {
+		if n.Type == html.ElementNode && n.Data == "base" {
+			for _, attr := range n.Attr {
+				if attr.Key == "href" && hasAnchoredBase(attr.Val) {
+					return 0
+				}
+			}
+		}
+	}
+
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode || n.Data == "base" {
+			continue
+		}
+
+		for i, attr := range n.Attr {
+			switch attr.Key {
+			case "href", "src", "poster":
+				n.Attr[i].Val = anchor(attr.Val)
+			case "srcset":
+				candidates := strings.Split(attr.Val, ",")
+				for j, candidate := range candidates {
+					fields := strings.Fields(candidate)
+					if len(fields) == 0 {
+						continue
+					}
+					fields[0] = anchor(fields[0])
+					candidates[j] = strings.Join(fields, " ")
+				}
+				n.Attr[i].Val = strings.Join(candidates, ", ")
+			}
+		}
+	}
+
+	return rewritten
+}
+
+// relativeReferences finds the relative urls in a rendered 404 page, using the same scanner as the link checker
+func relativeReferences(rendered []byte) []linkChecker.Reference {
+	doc := linkChecker.Scan(rendered)
+	if hasAnchoredBase(doc.Base) {
+		return nil
+	}
+
+	var relative []linkChecker.Reference
+	for _, ref := range doc.References {
+		if linkChecker.IsRelative(ref.URL) {
+			relative = append(relative, ref)
+		}
+	}
+	return relative
+}
+
+// sourceLine finds the line of the source file that u is written on, starting at 1, or 0 if it is not written there.
+// urls are escaped in html, so "a?b&c" can also be written as "a?b&amp;c"
+func sourceLine(source []byte, u string) int {
+	escaped := html.EscapeString(u)
+	for i, line := range strings.Split(string(source), "\n") {
+		if strings.Contains(line, u) || strings.Contains(line, escaped) {
+			return i + 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package building
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sklair/htmlUtilities"
+	"sort"
+	"strings"
+)
+
+// PreviewDir is where the component previews are written to in development builds, relative to the output directory
+const PreviewDir = "_sklair/preview"
+
+// PreviewSuffix marks the optional fixture of a component, i.e. MenuBar.preview.json for MenuBar.html
+const PreviewSuffix = ".preview.json"
+
+// PreviewVariant is a single way of using a component, rendered as <Component attributes...>body</Component>
+type PreviewVariant struct {
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes"`
+	Body       string            `json:"body"` // raw html
+}
+
+type PreviewFixture struct {
+	Title    string            `json:"title"`
+	Variants []*PreviewVariant `json:"variants"`
+}
+
+type preview struct {
+	RelPath string // relative to the output directory
+	Source  string // the component file, for error messages
+	Content []byte
+}
+
+func loadPreviewFixture(componentsDir string, fileName string) (*PreviewFixture, error) {
+	path := filepath.Join(componentsDir, strings.TrimSuffix(fileName, filepath.Ext(fileName))+PreviewSuffix)
+
+	fixture := &PreviewFixture{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(data, fixture); err != nil {
+		return nil, fmt.Errorf("invalid preview fixture %s : %s", path, err.Error())
+	}
+
+	for i, variant := range fixture.Variants {
+		if variant == nil {
+			return nil, fmt.Errorf("invalid preview fixture %s : variant %d is null", path, i+1)
+		}
+		for k := range variant.Attributes {
+			if !htmlUtilities.ValidAttributeName(k) {
+				return nil, fmt.Errorf("invalid preview fixture %s : variant %q has the invalid attribute name %q", path, variant.Name, k)
+			}
+		}
+	}
+
+	if fixture.Title == "" {
+		fixture.Title = strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	}
+	if len(fixture.Variants) == 0 {
+		fixture.Variants = []*PreviewVariant{{Name: "default"}}
+	}
+
+	return fixture, nil
+}
+
+const previewLabelStyle = "margin:32px 16px 8px;font:600 12px/1.4 system-ui,sans-serif;color:#888;text-transform:uppercase;letter-spacing:.05em"
+
+func previewPage(title string, body string) []byte {
+	return []byte("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n" +
+		"<meta name=\"robots\" content=\"noindex\">\n<title>" + html.EscapeString(title) + " - Sklair preview</title>\n</head>\n<body>\n" + body + "</body>\n</html>\n")
+}
+
+func previewDocument(tag string, fixture *PreviewFixture) []byte {
+	var b strings.Builder
+
+	b.WriteString(`<div style="` + previewLabelStyle + `"><a href="./">all components</a></div>` + "\n")
+
+	for _, variant := range fixture.Variants {
+		name := variant.Name
+		if name == "" {
+			name = "default"
+		}
+		b.WriteString(`<div style="` + previewLabelStyle + `">` + html.EscapeString(name) + "</div>\n")
+
+		// sorted, so that the output is the same on every build
+		keys := make([]string, 0, len(variant.Attributes))
+		for k := range variant.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("<" + tag)
+		for _, k := range keys {
+			b.WriteString(" " + k + `="` + html.EscapeString(variant.Attributes[k]) + `"`)
+		}
+		b.WriteString(">" + variant.Body + "</" + tag + ">\n")
+	}
+
+	return previewPage(fixture.Title, b.String())
+}
+
+// makePreviews creates a preview page for every component, and an index page linking to all of them.
+// the pages are html sources which still have to be compiled like any other document
+func makePreviews(componentsDir string, components map[string]string) ([]*preview, error) {
+	tags := make([]string, 0, len(components))
+	for tag := range components {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var previews []*preview
+	var index strings.Builder
+
+	index.WriteString(`<h1 style="margin:32px 16px;font:600 24px system-ui,sans-serif">Components</h1>` + "\n<ul style=\"font:16px/2 system-ui,sans-serif\">\n")
+
+	for _, tag := range tags {
+		fileName := components[tag]
+
+		fixture, err := loadPreviewFixture(componentsDir, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		relPath := filepath.Join(PreviewDir, tag+".html")
+		previews = append(previews, &preview{
+			RelPath: relPath,
+			Source:  filepath.Join(componentsDir, fileName),
+			Content: previewDocument(tag, fixture),
+		})
+
+		variants := ""
+		if len(fixture.Variants) > 1 {
+			variants = fmt.Sprintf(" (%d variants)", len(fixture.Variants))
+		}
+		fmt.Fprintf(&index, "<li><a href=\"%s.html\">%s</a>%s</li>\n", tag, html.EscapeString(fixture.Title), variants)
+	}
+
+	index.WriteString("</ul>\n")
+
+	previews = append(previews, &preview{
+		RelPath: filepath.Join(PreviewDir, "index.html"),
+		Source:  componentsDir,
+		Content: previewPage("Components", index.String()),
+	})
+
+	return previews, nil
+}
@@ -0,0 +1,146 @@
+package siteData
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads every JSON, YAML and TOML file in dir into a single tree.
+// each file is available under its name without the extension, and subdirectories become nested tables,
+// so data/nav.json is data.nav and data/people/team.yaml is data.people.team
+//
+// a missing directory is not an error, because most projects simply do not have any data files
+func Load(dir string) (map[string]any, error) {
+	data := map[string]any{}
+
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return data, nil
+	}
+
+	sources := map[string]string{} // key path -> file it came from, to report duplicates
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(d.Name()))
+		if ext != ".json" && ext != ".yaml" && ext != ".yml" && ext != ".toml" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		value, err := decode(content, ext)
+		if err != nil {
+			return fmt.Errorf("could not parse %s : %s", path, err.Error())
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)), "/")
+
+		keyPath := strings.Join(keys, ".")
+		if other, ok := sources[keyPath]; ok {
+			return fmt.Errorf("%s and %s both define data.%s", other, path, keyPath)
+		}
+		sources[keyPath] = path
+
+		parent := data
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := parent[key].(map[string]any)
+			if !ok {
+				if _, exists := parent[key]; exists {
+					return fmt.Errorf("the directory of %s clashes with a data file of the same name", path)
+				}
+				next = map[string]any{}
+				parent[key] = next
+			}
+			parent = next
+		}
+
+		last := keys[len(keys)-1]
+		if _, exists := parent[last]; exists {
+			return fmt.Errorf("%s clashes with a data directory of the same name", path)
+		}
+		parent[last] = value
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func decode(content []byte, ext string) (any, error) {
+	var value any
+
+	switch ext {
+	case ".json":
+		if err := json.Unmarshal(content, &value); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &value); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		table := map[string]any{}
+		if _, err := toml.Decode(string(content), &table); err != nil {
+			return nil, err
+		}
+		value = table
+	}
+
+	return normalise(value), nil
+}
+
+// normalise turns the types that the different decoders produce into plain map[string]any and []any,
+// which is all that templates and the lua sandbox have to deal with.
+// yaml produces map[any]any for mappings with non-string keys, and toml produces []map[string]any for arrays of tables
+func normalise(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = normalise(item)
+		}
+		return v
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[fmt.Sprint(k)] = normalise(item)
+		}
+		return out
+	case []any:
+		for i, item := range v {
+			v[i] = normalise(item)
+		}
+		return v
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalise(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
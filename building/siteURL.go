@@ -0,0 +1,25 @@
+package building
+
+import (
+	"fmt"
+	"net/url"
+	"sklair/sklairConfig"
+	"strings"
+)
+
+// requireSiteURL returns the site url without a trailing slash, or an error explaining that feature needs it
+func requireSiteURL(config *sklairConfig.ProjectConfig, feature string) (string, error) {
+	if config.SiteURL == "" {
+		return "", fmt.Errorf("%s needs siteURL to be set in sklair.json, e.g. \"siteURL\": \"https://example.com\"", feature)
+	}
+
+	u, err := url.Parse(config.SiteURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("siteURL %q must be an absolute http or https URL, e.g. https://example.com", config.SiteURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("siteURL %q may not have a query or fragment", config.SiteURL)
+	}
+
+	return strings.TrimSuffix(config.SiteURL, "/"), nil
+}
@@ -0,0 +1,178 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sklair/discovery"
+	"sklair/sklairConfig"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// MaxURLs is the most urls a single sitemap may list, bigger sites get a sitemap index instead
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Page is a compiled page that may be listed in the sitemap
+type Page struct {
+	RelPath string    // output path, slash-separated
+	Path    string    // url path, i.e. /blog/ for blog/index.html
+	LastMod time.Time // zero if unknown
+	NoIndex bool      // the page asks search engines not to index it, or is a 404 page
+}
+
+type urlEntry struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod,omitempty"`
+	ChangeFreq string `xml:"changefreq,omitempty"`
+	Priority   string `xml:"priority,omitempty"`
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	URLs    []urlEntry `xml:"url"`
+}
+
+type sitemapEntry struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name       `xml:"sitemapindex"`
+	Xmlns    string         `xml:"xmlns,attr"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// NoIndex reports whether doc has <meta name="robots"> with noindex or none in its content
+func NoIndex(doc *html.Node) bool {
+	for n := range doc.Descendants() {
+		if n.Type != html.ElementNode || n.Data != "meta" {
+			continue
+		}
+
+		var name, content string
+		for _, attr := range n.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "name":
+				name = strings.ToLower(strings.TrimSpace(attr.Val))
+			case "content":
+				content = strings.ToLower(attr.Val)
+			}
+		}
+		if name != "robots" {
+			continue
+		}
+
+		for _, directive := range strings.Split(content, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "noindex" || directive == "none" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// absolute joins the site url and a url path
+func absolute(siteURL string, path string) string {
+	return strings.TrimSuffix(siteURL, "/") + (&url.URL{Path: path}).EscapedPath()
+}
+
+func writeXML(path string, v any) error {
+	out, err := xml.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append([]byte(xml.Header), append(out, '\n')...), 0644)
+}
+
+// Write writes sitemap.xml into outputDir, leaving out excluded and noindex pages.
+// past MaxURLs, the pages are split into sitemap-1.xml, sitemap-2.xml and so on, and sitemap.xml becomes their index.
+// it returns the files it wrote, relative to outputDir
+func Write(outputDir string, siteURL string, config *sklairConfig.Sitemap, pages []Page) ([]string, error) {
+	excludes := discovery.Patterns(config.Exclude)
+
+	rules := make([][]string, len(config.Rules))
+	for i, rule := range config.Rules {
+		rules[i] = discovery.Patterns([]string{rule.Path})
+	}
+
+	var entries []urlEntry
+	for _, page := range pages {
+		if page.NoIndex || discovery.IsExcluded(page.RelPath, excludes) {
+			continue
+		}
+
+		entry := urlEntry{Loc: absolute(siteURL, page.Path)}
+		switch {
+		case page.LastMod.IsZero():
+		case page.LastMod.Hour() == 0 && page.LastMod.Minute() == 0 && page.LastMod.Second() == 0:
+			// dates from front matter usually do not have a time
+			entry.LastMod = page.LastMod.Format(time.DateOnly)
+		default:
+			entry.LastMod = page.LastMod.Format(time.RFC3339)
+		}
+
+		for i, rule := range config.Rules {
+			if !discovery.IsExcluded(page.RelPath, rules[i]) {
+				continue
+			}
+
+			entry.ChangeFreq = rule.ChangeFreq
+			if rule.Priority != nil {
+				entry.Priority = strconv.FormatFloat(*rule.Priority, 'f', -1, 64)
+			}
+			break
+		}
+
+		entries = append(entries, entry)
+	}
+
+	slices.SortFunc(entries, func(a, b urlEntry) int {
+		return strings.Compare(a.Loc, b.Loc)
+	})
+
+	if len(entries) <= MaxURLs {
+		if err := writeXML(filepath.Join(outputDir, "sitemap.xml"), urlSet{Xmlns: namespace, URLs: entries}); err != nil {
+			return nil, fmt.Errorf("could not write sitemap.xml : %s", err.Error())
+		}
+		return []string{"sitemap.xml"}, nil
+	}
+
+	index := sitemapIndex{Xmlns: namespace}
+	var written []string
+	for i := 0; i*MaxURLs < len(entries); i++ {
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		chunk := entries[i*MaxURLs : min((i+1)*MaxURLs, len(entries))]
+
+		if err := writeXML(filepath.Join(outputDir, name), urlSet{Xmlns: namespace, URLs: chunk}); err != nil {
+			return nil, fmt.Errorf("could not write %s : %s", name, err.Error())
+		}
+
+		index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: absolute(siteURL, "/"+name)})
+		written = append(written, name)
+	}
+
+	if err := writeXML(filepath.Join(outputDir, "sitemap.xml"), index); err != nil {
+		return nil, fmt.Errorf("could not write sitemap.xml : %s", err.Error())
+	}
+
+	return append(written, "sitemap.xml"), nil
+}
+
+// WriteRobots writes a robots.txt which allows everything and points to the sitemap
+func WriteRobots(outputDir string, siteURL string) error {
+	content := "User-agent: *\nAllow: /\n\nSitemap: " + absolute(siteURL, "/sitemap.xml") + "\n"
+	return os.WriteFile(filepath.Join(outputDir, "robots.txt"), []byte(content), 0644)
+}
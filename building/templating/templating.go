@@ -0,0 +1,231 @@
+package templating
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sklair/building/directives"
+	"sklair/htmlUtilities"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// Context holds the variables that templates can use, i.e. "page" for the front matter of the current page and "data" for the data files
+type Context map[string]any
+
+var expression = regexp.MustCompile(`\{\{\s*([A-Za-z_][\w-]*(?:\.[\w-]+)*)\s*\}\}`)
+
+// Lookup resolves a dotted path, such as page.title, to its value
+func (c Context) Lookup(path string) (any, bool) {
+	var current any = map[string]any(c)
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := current.(type) {
+		case map[string]any:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// Format turns a value into the text that is put into the document
+func Format(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		// YAML dates without a time, which is by far the most common case for front matter
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Interpolate replaces every {{ variable }} in s.
+// expressions whose first part is not in the context are kept as-is, because they probably belong to
+// some client-side framework. variables that do not exist in a known part of the context are returned as missing
+func (c Context) Interpolate(s string) (string, []string) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	var missing []string
+
+	out := expression.ReplaceAllStringFunc(s, func(match string) string {
+		path := expression.FindStringSubmatch(match)[1]
+
+		root, _, _ := strings.Cut(path, ".")
+		if _, known := c[root]; !known {
+			return match
+		}
+
+		v, ok := c.Lookup(path)
+		if !ok {
+			missing = append(missing, path)
+			return ""
+		}
+
+		return Format(v)
+	})
+
+	return out, missing
+}
+
+// elements whose contents are never interpolated, because they are code
+var skipped = map[string]bool{
+	"script": true,
+	"style":  true,
+	"pre":    true,
+	"code":   true,
+}
+
+// Apply interpolates every text node and attribute value below n, except inside of <script>, <style>, <pre> and <code>,
+// and expands every <!-- sklair:each item in list --> ... <!-- sklair:each-end --> loop.
+// it returns every variable that could not be found
+func (c Context) Apply(n *html.Node) ([]string, error) {
+	var missing []string
+
+	var walk func(*html.Node) error
+	walk = func(n *html.Node) error {
+		switch n.Type {
+		case html.TextNode:
+			var m []string
+			n.Data, m = c.Interpolate(n.Data)
+			missing = append(missing, m...)
+		case html.ElementNode:
+			for i := range n.Attr {
+				var m []string
+				n.Attr[i].Val, m = c.Interpolate(n.Attr[i].Val)
+				missing = append(missing, m...)
+			}
+
+			if skipped[n.Data] {
+				return nil
+			}
+		}
+
+		child := n.FirstChild
+		for child != nil {
+			if ok, _, _ := directives.IsEachStart(child); ok {
+				next, m, err := c.expandEach(child)
+				if err != nil {
+					return err
+				}
+				missing = append(missing, m...)
+				child = next
+				continue
+			}
+
+			if directives.IsEachEnd(child) {
+				return errors.New("found <!-- sklair:each-end --> without a matching <!-- sklair:each -->")
+			}
+
+			if err := walk(child); err != nil {
+				return err
+			}
+			child = child.NextSibling
+		}
+
+		return nil
+	}
+
+	if err := walk(n); err != nil {
+		return missing, err
+	}
+
+	return missing, nil
+}
+
+// expandEach repeats everything between start and its matching sklair:each-end once for every item of the list,
+// with the loop variable added to the context. it returns the node after the loop, which is where walking continues
+func (c Context) expandEach(start *html.Node) (*html.Node, []string, error) {
+	_, name, list := directives.IsEachStart(start)
+	if name == "" {
+		return nil, nil, fmt.Errorf("invalid loop <!--%s-->, expected <!-- sklair:each item in list -->", start.Data)
+	}
+
+	// nested loops have their own sklair:each-end, so only the one at the same depth ends this loop
+	var body []*html.Node
+	var end *html.Node
+	depth := 0
+	for sibling := start.NextSibling; sibling != nil; sibling = sibling.NextSibling {
+		if ok, _, _ := directives.IsEachStart(sibling); ok {
+			depth++
+		} else if directives.IsEachEnd(sibling) {
+			if depth == 0 {
+				end = sibling
+				break
+			}
+			depth--
+		}
+		body = append(body, sibling)
+	}
+	if end == nil {
+		return nil, nil, fmt.Errorf("<!-- sklair:each %s in %s --> is missing its <!-- sklair:each-end -->", name, list)
+	}
+
+	var missing []string
+	var items []any
+
+	value, ok := c.Lookup(list)
+	switch {
+	case !ok:
+		// loops over something that does not exist simply render nothing, like any other undefined variable
+		missing = append(missing, list)
+	case value == nil:
+	default:
+		items, ok = value.([]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("<!-- sklair:each %s in %s --> can only loop over a list, but %s is not one", name, list, list)
+		}
+	}
+
+	parent := start.Parent
+	for _, item := range items {
+		inner := make(Context, len(c)+1)
+		for k, v := range c {
+			inner[k] = v
+		}
+		inner[name] = item
+
+		// the copies are expanded inside of a detached container, so that loops directly inside of this one are found too
+		container := &html.Node{Type: html.DocumentNode}
+		for _, node := range body {
+			container.AppendChild(htmlUtilities.Clone(node))
+		}
+
+		m, err := inner.Apply(container)
+		if err != nil {
+			return nil, nil, err
+		}
+		missing = append(missing, m...)
+
+		for _, node := range htmlUtilities.GetAllChildren(container) {
+			container.RemoveChild(node)
+			parent.InsertBefore(node, start)
+		}
+	}
+
+	next := end.NextSibling
+	parent.RemoveChild(start)
+	for _, node := range body {
+		parent.RemoveChild(node)
+	}
+	parent.RemoveChild(end)
+
+	return next, missing, nil
+}
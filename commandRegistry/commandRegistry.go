@@ -2,12 +2,22 @@ package commandRegistry
 
 // adapted from CommandRegistry intended for a bot in another numelon-proprietary project
 
-import "fmt"
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
 
 type Command struct {
 	Name        string
 	Description string
-	Run         func(args []string) int
+
+	// Flags registers the flags of the command, if it has any.
+	// they are parsed before Run is called, which then only receives the remaining positional arguments
+	Flags func(flags *flag.FlagSet)
+	Run   func(args []string) int
 }
 
 type CommandRegistry struct {
@@ -21,8 +31,20 @@ func New() *CommandRegistry {
 
 	r.Register(&Command{
 		Name:        "help",
-		Description: "Shows available commands",
+		Description: "Shows available commands, or the flags of a specific command",
 		Run: func(args []string) int {
+			if len(args) > 0 {
+				cmd, ok := r.Get(args[0])
+				if !ok {
+					_, _ = fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", args[0])
+					r.PrintHelp()
+					return 2
+				}
+
+				r.PrintCommandHelp(cmd)
+				return 0
+			}
+
 			r.PrintHelp()
 			return 0
 		},
@@ -40,6 +62,31 @@ func (r *CommandRegistry) Get(name string) (*Command, bool) {
 	return cmd, ok
 }
 
+func (r *CommandRegistry) flagSet(cmd *Command) *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	if cmd.Flags != nil {
+		cmd.Flags(flags)
+	}
+	return flags
+}
+
+// Execute parses the flags of the command from args and then runs it
+func (r *CommandRegistry) Execute(cmd *Command, args []string) int {
+	flags := r.flagSet(cmd)
+	flags.Usage = func() {
+		r.PrintCommandHelp(cmd)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	return cmd.Run(flags.Args())
+}
+
 func (r *CommandRegistry) PrintHelp() {
 	fmt.Println("Usage:")
 	fmt.Println("\tsklair [verbosity flags] <command> [arguments]")
@@ -52,8 +99,15 @@ func (r *CommandRegistry) PrintHelp() {
 	fmt.Println()
 	fmt.Println("Available commands:")
 
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	seen := make(map[*Command]bool)
-	for _, cmd := range r.commands {
+	for _, name := range names {
+		cmd := r.commands[name]
 		if seen[cmd] {
 			continue
 		}
@@ -61,6 +115,37 @@ func (r *CommandRegistry) PrintHelp() {
 
 		fmt.Printf("\t%-12s %s\n", cmd.Name, cmd.Description)
 	}
-	//fmt.Println()
-	//fmt.Println("\tUse 'sklair help <command>' to get help for a specific command.") // TODO: todo!!
+	fmt.Println()
+	fmt.Println("\tUse 'sklair help <command>' to get help for a specific command.")
+}
+
+func (r *CommandRegistry) PrintCommandHelp(cmd *Command) {
+	fmt.Println("Usage:")
+	fmt.Printf("\tsklair [verbosity flags] %s [flags]\n", cmd.Name)
+	fmt.Println()
+	fmt.Println(cmd.Description)
+
+	flags := r.flagSet(cmd)
+
+	hasFlags := false
+	flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if !hasFlags {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Available flags:")
+	flags.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		if name != "" {
+			name = " " + name
+		}
+
+		def := ""
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			def = fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+
+		fmt.Printf("\t--%s%s: %s%s\n", f.Name, name, usage, def)
+	})
 }
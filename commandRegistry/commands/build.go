@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"flag"
+	"path/filepath"
 	"sklair/building"
 	"sklair/commandRegistry"
 	"sklair/logger"
@@ -8,9 +10,18 @@ import (
 )
 
 func init() {
+	var (
+		checkLinksFlag bool
+		external       bool
+	)
+
 	commandRegistry.Registry.Register(&commandRegistry.Command{
 		Name:        "build",
 		Description: "Builds a Sklair project",
+		Flags: func(flags *flag.FlagSet) {
+			flags.BoolVar(&checkLinksFlag, "check-links", false, "Check the links in the output once it is built, see sklair check")
+			flags.BoolVar(&external, "external", false, "Together with --check-links, also request every link to other sites")
+		},
 		Run: func(args []string) int {
 			config, configDir, err := sklairConfig.LoadProjectConfig()
 			if err != nil {
@@ -18,12 +29,16 @@ func init() {
 				return 1
 			}
 
-			err = building.Build(config, configDir, "")
+			err = building.Build(config, configDir, "", false)
 			if err != nil {
-				logger.Error(err.Error())
+				logger.Error("%s", err.Error())
 				return 1
 			}
 
+			if checkLinksFlag {
+				return checkLinks(config, filepath.Join(configDir, config.Output), external)
+			}
+
 			return 0
 		},
 	})
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sklair/commandRegistry"
+	"sklair/linkChecker"
+	"sklair/logger"
+	"sklair/sklairConfig"
+)
+
+func init() {
+	var external bool
+
+	commandRegistry.Registry.Register(&commandRegistry.Command{
+		Name:        "check",
+		Description: "Checks the links in the output of an already built Sklair project",
+		Flags: func(flags *flag.FlagSet) {
+			flags.BoolVar(&external, "external", false, "Also request every link to other sites, which is slow and needs network access")
+		},
+		Run: func(args []string) int {
+			config, configDir, err := sklairConfig.LoadProjectConfig()
+			if err != nil {
+				logger.Error("could not load sklair.json : %s", err.Error())
+				return 1
+			}
+
+			outputDir := filepath.Join(configDir, config.Output)
+			if _, err := os.Stat(outputDir); errors.Is(err, fs.ErrNotExist) {
+				logger.Error("%s does not exist, run sklair build first", outputDir)
+				return 1
+			}
+
+			return checkLinks(config, outputDir, external)
+		},
+	})
+}
+
+// checkLinks reports every broken link in outputDir and returns the exit code for it
+func checkLinks(config *sklairConfig.ProjectConfig, outputDir string, external bool) int {
+	logger.Info("Checking links in %s...", outputDir)
+
+	problems, err := linkChecker.Check(outputDir, linkChecker.Options{
+		SiteURL:  config.SiteURL,
+		External: external,
+	})
+	if err != nil {
+		logger.Error("could not check links : %s", err.Error())
+		return 1
+	}
+
+	for _, problem := range problems {
+		logger.Warning("%s", problem.String())
+	}
+	if len(problems) > 0 {
+		logger.Error("found %d broken links", len(problems))
+		return 1
+	}
+
+	logger.Info("No broken links found")
+	return 0
+}
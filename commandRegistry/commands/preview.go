@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sklair/commandRegistry"
+	"sklair/devserver"
+	"sklair/logger"
+	"sklair/sklairConfig"
+	"sklair/util"
+	"syscall"
+)
+
+func init() {
+	var (
+		host string
+		port int
+		open bool
+	)
+
+	commandRegistry.Registry.Register(&commandRegistry.Command{
+		Name:        "preview",
+		Description: "Serves the output directory exactly like a static host would, to test what gets deployed",
+		Flags: func(flags *flag.FlagSet) {
+			flags.StringVar(&host, "host", "localhost", "The `host` to bind to, use 0.0.0.0 to make the server reachable from other devices")
+			flags.IntVar(&port, "port", 0, "The `port` to bind to, by default the first free port from 8080 to 8090")
+			flags.BoolVar(&open, "open", false, "Open the site in the default browser")
+		},
+		Run: func(args []string) int {
+			config, configDir, err := sklairConfig.LoadProjectConfig()
+			if err != nil {
+				logger.Error("could not load sklair.json : %s", err.Error())
+				return 1
+			}
+
+			outputDir := filepath.Join(configDir, config.Output)
+			if info, err := os.Stat(outputDir); err != nil || !info.IsDir() {
+				logger.Error("%s does not exist, run sklair build first", outputDir)
+				return 1
+			}
+
+			server, err := devserver.NewPreviewServer(outputDir)
+			if err != nil {
+				logger.Error("could not load the host configuration : %s", err.Error())
+				return 1
+			}
+
+			listener, port, err := devserver.AcquirePort(host, port)
+			if err != nil {
+				logger.Error("could not acquire port : %s", err.Error())
+				return 1
+			}
+			defer listener.Close()
+
+			url := devserver.URL(host, port, false)
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			serveErrs := make(chan error, 1)
+			go func() {
+				serveErrs <- server.Serve(listener)
+			}()
+			logger.Info("Serving %s on %s", outputDir, url)
+
+			if open {
+				if err := util.OpenBrowser(url); err != nil {
+					logger.Warning("could not open the browser : %s", err.Error())
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				stop()
+				logger.Info("Shutting down...")
+			case err := <-serveErrs:
+				if !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("the preview server stopped unexpectedly : %s", err.Error())
+					return 1
+				}
+			}
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				logger.Warning("could not shut down the preview server gracefully : %s", err.Error())
+			}
+
+			return 0
+		},
+	})
+}
@@ -1,12 +1,24 @@
 package commands
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sklair/building"
 	"sklair/commandRegistry"
 	"sklair/devserver"
 	"sklair/logger"
 	"sklair/sklairConfig"
+	"sklair/util"
+	"strings"
+	"syscall"
+	"time"
 )
 
 // REBUILDING ONLY CHANGES FILES:
@@ -22,16 +34,30 @@ import (
 // therefore ONLY process (build) changed HtmlFiles, not StaticFiles
 // but this still requires a bit of work but its much easier than the former
 
-// TODO: add the following flags
-// port (default is 8080 upwards)
-// host (default is 127.0.0.1)
-// --open (opens browser)
-// --auto_refresh=true|false (websocket control, default true)
-// --watch=true|false (watch for changes, default true)
+// how long in-flight requests get to finish once sklair serve is stopped
+const shutdownTimeout = 5 * time.Second
+
 func init() {
+	var (
+		host     string
+		port     int
+		open     bool
+		noReload bool
+		noWatch  bool
+		https    bool
+	)
+
 	commandRegistry.Registry.Register(&commandRegistry.Command{
 		Name:        "serve",
 		Description: "Continuously builds and serves a Sklair project for development purposes",
+		Flags: func(flags *flag.FlagSet) {
+			flags.StringVar(&host, "host", "localhost", "The `host` to bind to, use 0.0.0.0 to make the server reachable from other devices")
+			flags.IntVar(&port, "port", 0, "The `port` to bind to, by default the first free port from 8080 to 8090")
+			flags.BoolVar(&open, "open", false, "Open the site in the default browser once it is built")
+			flags.BoolVar(&noReload, "no-reload", false, "Do not reload pages in the browser after a rebuild")
+			flags.BoolVar(&noWatch, "no-watch", false, "Build once and serve, without watching for changes")
+			flags.BoolVar(&https, "https", false, "Serve over HTTPS with a certificate from a local certificate authority in ~/.sklair/certs")
+		},
 		Run: func(args []string) int {
 			config, configDir, err := sklairConfig.LoadProjectConfig()
 			if err != nil {
@@ -39,72 +65,240 @@ func init() {
 				return 1
 			}
 
+			proxies, err := devserver.NewProxies(config.Proxy)
+			if err != nil {
+				logger.Error("invalid proxy configuration : %s", err.Error())
+				return 1
+			}
+
 			tmp, err := os.MkdirTemp("", "sklair-")
 			if err != nil {
 				logger.Error("could not create temporary directory : %s", err.Error())
 				return 1
 			}
 			defer os.RemoveAll(tmp)
+			// every build goes to a staging directory next to this one first, see buildAndSwap
+			served := filepath.Join(tmp, "site")
 
-			listener, port, err := devserver.AcquirePort("localhost", 0)
+			listener, port, err := devserver.AcquirePort(host, port)
 			if err != nil {
 				logger.Error("could not acquire port : %s", err.Error())
 				return 1
 			}
 			defer listener.Close()
 
-			wsThing := devserver.NewWS()
+			url := devserver.URL(host, port, https)
+
+			if https {
+				globalDir, err := sklairConfig.GlobalDir()
+				if err != nil {
+					logger.Error("could not find the home directory : %s", err.Error())
+					return 1
+				}
+
+				cert, caPath, err := devserver.LocalCertificate(filepath.Join(globalDir, "certs"), devserver.CertificateHosts(host))
+				if err != nil {
+					logger.Error("could not create a development certificate : %s", err.Error())
+					return 1
+				}
+				logger.Info("Browsers only trust the certificate once %s is imported as a trusted certificate authority", caPath)
+
+				// the websocket shares the listener, so it is served over TLS too
+				listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{cert}})
+			}
+
+			// Ctrl+C and SIGTERM cancel ctx, which lets every deferred cleanup run
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			var wsThing *devserver.WS
+			if !noReload {
+				wsThing = devserver.NewWS()
+			}
+
+			server := devserver.NewServer(served, wsThing, proxies)
+			serveErrs := make(chan error, 1)
+			go func() {
+				serveErrs <- server.Serve(listener)
+			}()
+			logger.Info("Will be listening on %s", url)
+			logger.Info("Component previews are available at %s%s/", url, building.PreviewDir)
+
+			defer func() {
+				// a second Ctrl+C while shutting down kills sklair right away
+				stop()
+
+				// websocket connections are hijacked, so Shutdown would not wait for them anyway
+				if wsThing != nil {
+					wsThing.Close()
+				}
 
-			// TODO: we need to be able to check whether the server started successfully in the first place or not
-			// otherwise we are just walking in blind here
-			// and dont know whether the file server is running or not
-			// whilst still tracking the filesystem and recompiling every time...
-			go devserver.Serve(listener, tmp, port, wsThing)
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+				defer cancel()
+				if err := server.Shutdown(shutdownCtx); err != nil {
+					logger.Warning("could not shut down the dev server gracefully : %s", err.Error())
+				}
+			}()
 
-			err = building.Build(config, configDir, tmp)
+			err = buildAndSwap(config, configDir, served, !noReload)
 			if err != nil {
-				logger.Error(err.Error())
-				return 1
+				logger.Error("%s", err.Error())
+				// with nothing to watch, the build would never get another chance to succeed
+				if noWatch {
+					return 1
+				}
+				sendBuildResult(wsThing, err, devserver.MessageReload)
 			}
 
-			// TODO: add port flag, auto_refresh bool (websocket) flag
-			// track changes from the following directories:
-			// - source directory (excluding components dir, if it is within the source directory)
-			// OR if the components directory is within the source directory then just ONLY track the source directory anyways
-			// - components directory by itself
-			// from all tracked directories, output dir must be excluded along with common excluded directories
+			if open {
+				if err := util.OpenBrowser(url); err != nil {
+					logger.Warning("could not open the browser : %s", err.Error())
+				}
+			}
+
+			// track changes from the source, components, data and hooks directories.
+			// the output dir is never tracked, along with the excluded and common excluded directories
 			// for now: ENTIRE project is rebuild on change
 
 			// but in the future maybe only rebuild changed files: see comment at very top
 
-			// try all ports from 8080 upwards (but obviously at some point theres a limit)
 			// websocket lives on same http, just connection upgrade
-			// after decided, they are now just hardcoded
 
-			events, errs := devserver.Watch(config.Input)
+			// without watching, both channels stay nil and therefore never receive anything
+			var events <-chan []string
+			var errs <-chan error
+
+			inputDir := filepath.Join(configDir, config.Input)
+			notServed := []string{
+				filepath.Join(configDir, config.Components),
+				filepath.Join(configDir, config.Data),
+			}
+			if config.Hooks != nil && config.Hooks.Enabled {
+				notServed = append(notServed, filepath.Join(configDir, config.Hooks.Path))
+			}
+
+			if !noWatch {
+				roots := append([]string{inputDir}, notServed...)
+				ignored := []string{filepath.Join(configDir, config.Output), tmp}
+
+				events, errs = devserver.Watch(ctx, roots, config.Exclude, ignored)
+			}
 
 			for {
 				select {
-				case <-events:
-					//_ = os.RemoveAll(tmp)
-					//_ = os.MkdirAll(tmp, 0755)
+				case <-ctx.Done():
+					logger.Info("Shutting down...")
+					return 0
+				case err := <-serveErrs:
+					if !errors.Is(err, http.ErrServerClosed) {
+						logger.Error("the dev server stopped unexpectedly : %s", err.Error())
+						return 1
+					}
+				case changed, ok := <-events:
+					if !ok {
+						events = nil
+						continue
+					}
 
-					err = building.Build(config, configDir, tmp)
+					// a failed build must not take the server down, the error is shown in the browser instead
+					err = buildAndSwap(config, configDir, served, !noReload)
 					if err != nil {
-						logger.Error(err.Error())
-						return 1
+						logger.Error("%s", err.Error())
+					}
+					sendBuildResult(wsThing, err, changeType(changed, inputDir, notServed))
+				case err, ok := <-errs:
+					if !ok {
+						errs = nil
+						continue
 					}
 
-					wsThing.Send <- "reload"
-				case err := <-errs:
-					logger.Error(err.Error())
+					logger.Error("%s", err.Error())
 				}
 			}
-
-			// TODO: add a channel which is used for receiving Ctrl+C signals for graceful shutdown,
-			// perhaps supply that channel to the Watch function to make all the defers run
-
-			return 0
 		},
 	})
 }
+
+// buildAndSwap builds the project into a staging directory and only swaps it in for served once the build succeeded,
+// so that a failed rebuild keeps the last working version of the site in the browser
+func buildAndSwap(config *sklairConfig.ProjectConfig, configDir string, served string, liveReload bool) error {
+	staging := served + ".next"
+	old := served + ".old"
+
+	err := building.Build(config, configDir, staging, liveReload)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return err
+	}
+
+	err = os.RemoveAll(old)
+	if err != nil {
+		return fmt.Errorf("could not remove the previous build %s : %s", old, err.Error())
+	}
+
+	// the first build has nothing to replace yet
+	err = os.Rename(served, old)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not move the current build out of the way : %s", err.Error())
+	}
+
+	err = os.Rename(staging, served)
+	if err != nil {
+		return fmt.Errorf("could not swap in the new build : %s", err.Error())
+	}
+
+	_ = os.RemoveAll(old)
+	return nil
+}
+
+// changeType decides how the browser should pick up the changed files.
+// only stylesheets that are copied from inputDir into the site can be swapped in place,
+// the ones in notServed (components, data and hooks) are not, even when they are inside of inputDir.
+// stylesheets of components in particular only have their links added to pages during the build
+func changeType(changed []string, inputDir string, notServed []string) string {
+	for _, path := range changed {
+		if !strings.EqualFold(filepath.Ext(path), ".css") || !isInside(inputDir, path) {
+			return devserver.MessageReload
+		}
+
+		for _, dir := range notServed {
+			if isInside(dir, path) {
+				return devserver.MessageReload
+			}
+		}
+	}
+
+	return devserver.MessageCSS
+}
+
+// isInside reports whether path is dir itself or anywhere below it
+func isInside(dir string, path string) bool {
+	dir, _ = filepath.Abs(dir)
+	path, _ = filepath.Abs(path)
+
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sendBuildResult sends the given message type to the connected pages after a successful build, or shows the error overlay otherwise
+func sendBuildResult(wsThing *devserver.WS, err error, onSuccess string) {
+	if wsThing == nil {
+		return
+	}
+
+	if err == nil {
+		wsThing.Send <- devserver.Message{Type: onSuccess}
+		return
+	}
+
+	msg := devserver.Message{Type: devserver.MessageError, Message: err.Error()}
+
+	var buildErr *building.BuildError
+	if errors.As(err, &buildErr) {
+		msg.File = buildErr.File
+		msg.Line = buildErr.Line
+		msg.Message = buildErr.Err.Error()
+	}
+
+	wsThing.Send <- msg
+}
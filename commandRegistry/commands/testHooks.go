@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"sklair/building/hooks"
+	"sklair/commandRegistry"
+	"sklair/logger"
+	"sklair/sklairConfig"
+)
+
+func init() {
+	var format, output string
+
+	commandRegistry.Registry.Register(&commandRegistry.Command{
+		Name:        "test-hooks",
+		Description: "Runs the *_test.lua files inside the hooks directory",
+		Flags: func(flags *flag.FlagSet) {
+			flags.StringVar(&format, "format", "tap", "Report `format`, either tap or junit")
+			flags.StringVar(&output, "output", "", "Write the report to this `file` instead of stdout")
+		},
+		Run: func(args []string) int {
+			if format != "tap" && format != "junit" {
+				logger.Error("unknown report format %q, expected tap or junit", format)
+				return 2
+			}
+
+			config, configDir, err := sklairConfig.LoadProjectConfig()
+			if err != nil {
+				logger.Error("could not load sklair.json : %s", err.Error())
+				return 1
+			}
+
+			hooksPath := sklairConfig.DefaultConfig.Hooks.Path
+			if config.Hooks != nil && config.Hooks.Path != "" {
+				hooksPath = config.Hooks.Path
+			}
+			hooksDir := filepath.Join(configDir, hooksPath)
+
+			cases, err := hooks.RunTests(hooksDir, config)
+			if err != nil {
+				logger.Error("could not run hook tests : %s", err.Error())
+				return 1
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					logger.Error("could not create %s : %s", output, err.Error())
+					return 1
+				}
+				defer f.Close()
+				w = f
+			}
+
+			if format == "junit" {
+				err = hooks.WriteJUnit(w, cases)
+			} else {
+				err = hooks.WriteTAP(w, cases)
+			}
+			if err != nil {
+				logger.Error("could not write report : %s", err.Error())
+				return 1
+			}
+
+			failed := 0
+			for _, tc := range cases {
+				if !tc.Passed {
+					failed++
+				}
+			}
+
+			logger.Info("%d of %d hook tests passed", len(cases)-failed, len(cases))
+			if failed > 0 {
+				return 1
+			}
+
+			return 0
+		},
+	})
+}
@@ -0,0 +1,283 @@
+package devserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sklair/logger"
+	"slices"
+	"time"
+)
+
+const (
+	caCertFile   = "ca.pem"
+	caKeyFile    = "ca-key.pem"
+	leafCertFile = "dev.pem"
+	leafKeyFile  = "dev-key.pem"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// browsers reject leaf certificates that are valid for longer than 398 days, even from a trusted CA
+	leafValidity = 365 * 24 * time.Hour
+	// a leaf that expires sooner than this is issued again
+	leafRenewal = 30 * 24 * time.Hour
+)
+
+// CertificateHosts returns the names the dev server certificate must be valid for when binding to host.
+// when binding to every interface, the addresses of this machine in the local network are included too,
+// so that other devices such as phones can open the site
+func CertificateHosts(host string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+
+	switch host {
+	case "", "0.0.0.0", "::":
+		if name, err := os.Hostname(); err == nil {
+			hosts = append(hosts, name)
+		}
+
+		addrs, _ := net.InterfaceAddrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	default:
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func writePEM(path string, blockType string, der []byte, mode os.FileMode) error {
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), mode)
+}
+
+func readPEM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data in " + path)
+	}
+
+	return block.Bytes, nil
+}
+
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return writePEM(path, "EC PRIVATE KEY", der, 0600)
+}
+
+func loadCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certDER, err := readPEM(filepath.Join(dir, caCertFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := readPEM(filepath.Join(dir, caKeyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if time.Now().After(cert.NotAfter) {
+		return nil, nil, errors.New("the local CA has expired")
+	}
+
+	return cert, key, nil
+}
+
+func createCA(dir string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Sklair development CA"}, CommonName: "Sklair development CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := writeKey(filepath.Join(dir, caKeyFile), key); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(filepath.Join(dir, caCertFile), "CERTIFICATE", der, 0644); err != nil {
+		return nil, nil, err
+	}
+
+	return cert, key, nil
+}
+
+// leafCovers reports whether the cached leaf is still usable for hosts, and otherwise which names it already had
+func leafCovers(dir string, ca *x509.Certificate, hosts []string) (bool, []string) {
+	der, err := readPEM(filepath.Join(dir, leafCertFile))
+	if err != nil {
+		return false, nil
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil || cert.CheckSignatureFrom(ca) != nil {
+		return false, nil
+	}
+
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	if time.Until(cert.NotAfter) < leafRenewal {
+		return false, names
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false, names
+		}
+	}
+
+	return true, names
+}
+
+func createLeaf(dir string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Sklair development certificate"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	if err := writeKey(filepath.Join(dir, leafKeyFile), key); err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(dir, leafCertFile), "CERTIFICATE", der, 0644)
+}
+
+// LocalCertificate returns a certificate for hosts that is signed by a local CA, along with the path of the CA certificate.
+// both are created in dir on first use and reused afterwards. the leaf certificate is issued again
+// when it is about to expire or does not cover every host, keeping the names it already had.
+//
+// browsers only trust the certificate once the CA has been imported into the trust store of the system or browser
+func LocalCertificate(dir string, hosts []string) (tls.Certificate, string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return tls.Certificate{}, "", err
+	}
+
+	caPath := filepath.Join(dir, caCertFile)
+
+	// an existing CA may already be trusted by the system, so it is never replaced, even when it cannot be loaded
+	_, certErr := os.Stat(caPath)
+	_, keyErr := os.Stat(filepath.Join(dir, caKeyFile))
+	caMissing := os.IsNotExist(certErr) && os.IsNotExist(keyErr)
+
+	var ca *x509.Certificate
+	var caKey *ecdsa.PrivateKey
+	var err error
+
+	if caMissing {
+		logger.Info("Creating a local certificate authority in %s...", dir)
+		ca, caKey, err = createCA(dir)
+		if err != nil {
+			return tls.Certificate{}, "", err
+		}
+		logger.Warning("Created a new local certificate authority, import %s into the trust store of your system or browser to avoid certificate warnings", caPath)
+		// a leftover leaf was signed by a CA that does not exist anymore
+		_ = os.Remove(filepath.Join(dir, leafCertFile))
+	} else {
+		ca, caKey, err = loadCA(dir)
+		if err != nil {
+			return tls.Certificate{}, "", fmt.Errorf("could not load the local certificate authority in %s, delete %s and %s to create a new one : %s", dir, caCertFile, caKeyFile, err.Error())
+		}
+	}
+
+	if ok, existing := leafCovers(dir, ca, hosts); !ok {
+		for _, name := range existing {
+			if !slices.Contains(hosts, name) {
+				hosts = append(hosts, name)
+			}
+		}
+
+		logger.Info("Issuing a development certificate for %v...", hosts)
+		if err := createLeaf(dir, ca, caKey, hosts); err != nil {
+			return tls.Certificate{}, "", err
+		}
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, leafCertFile), filepath.Join(dir, leafKeyFile))
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+
+	return cert, caPath, nil
+}
@@ -12,7 +12,9 @@ import (
 	"golang.org/x/net/websocket"
 )
 
-func AcquirePort(addr string, port int) (net.Listener, int, error) {
+// AcquirePort binds to the given host and port.
+// a port of 0 means the first free port between 8080 and 8090.
+func AcquirePort(host string, port int) (net.Listener, int, error) {
 	var listener net.Listener
 	var err error
 
@@ -22,7 +24,7 @@ func AcquirePort(addr string, port int) (net.Listener, int, error) {
 			port = 8080 + i
 
 			logger.Debug("trying port %d", port)
-			listener, err = net.Listen("tcp", addr+":"+strconv.Itoa(port))
+			listener, err = net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 			if err == nil {
 				break
 			}
@@ -33,7 +35,7 @@ func AcquirePort(addr string, port int) (net.Listener, int, error) {
 		}
 	} else {
 		// otherwise, an explicit port was provided so TRY to bind to it regularly
-		listener, err = net.Listen("tcp", addr+":"+strconv.Itoa(port))
+		listener, err = net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 		if err != nil {
 			return nil, 0, err
 		}
@@ -60,7 +62,25 @@ func try404(root string, w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "404 not found", http.StatusNotFound)
 }
 
-func Serve(listener net.Listener, tmp string, port int, wsThing *WS) {
+// URL returns the address that the dev server can be opened at in a browser.
+// wildcard hosts such as 0.0.0.0 are not reachable by browsers, so localhost is used instead
+func URL(host string, port int, secure bool) string {
+	switch host {
+	case "", "0.0.0.0", "::":
+		host = "localhost"
+	}
+
+	scheme := "http://"
+	if secure {
+		scheme = "https://"
+	}
+
+	return scheme + net.JoinHostPort(host, strconv.Itoa(port)) + "/"
+}
+
+// NewServer creates the dev server for the files in tmp.
+// wsThing may be nil, in which case live reloading is disabled.
+func NewServer(tmp string, wsThing *WS, proxies []*Proxy) *http.Server {
 	staticHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("cache-control", "no-cache, no-store, must-revalidate")
 
@@ -78,11 +98,16 @@ func Serve(listener net.Listener, tmp string, port int, wsThing *WS) {
 	})
 
 	mux := http.NewServeMux()
-	mux.Handle("/"+WSPath, websocket.Handler(wsThing.HandleWS))
+	if wsThing != nil {
+		mux.Handle("/"+WSPath, websocket.Handler(wsThing.HandleWS))
+	}
 	mux.Handle("/", staticHandler)
-
-	logger.Info("Will be listening on http://localhost:" + strconv.Itoa(port) + "/")
-	if err := http.Serve(listener, mux); err != nil {
-		logger.Error(err.Error())
+	for _, p := range proxies {
+		// both the prefix itself and everything below it
+		mux.Handle(p.Prefix, p.Handler)
+		mux.Handle(p.Prefix+"/", p.Handler)
+		logger.Info("Proxying %s to %s", p.Prefix, p.Target)
 	}
+
+	return &http.Server{Handler: mux}
 }
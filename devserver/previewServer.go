@@ -0,0 +1,255 @@
+package devserver
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sklair/logger"
+	"strings"
+)
+
+const (
+	htmlCacheControl  = "public, max-age=0, must-revalidate"
+	assetCacheControl = "public, max-age=14400, must-revalidate"
+)
+
+// previewHandler serves a built site the way static hosts such as Netlify and Cloudflare Pages do
+type previewHandler struct {
+	root      string
+	headers   []*headerRule
+	redirects []*redirectRule
+}
+
+// NewPreviewServer creates a server for the output directory with production semantics:
+// _headers and _redirects, clean URLs, a real 404 status, precompressed .br and .gz files, and cache headers.
+// unlike NewServer, nothing is ever injected into the served pages
+func NewPreviewServer(root string) (*http.Server, error) {
+	h := &previewHandler{root: root}
+
+	var err error
+	if h.headers, err = parseHeaders(filepath.Join(root, "_headers")); err != nil {
+		return nil, err
+	}
+	if h.redirects, err = parseRedirects(filepath.Join(root, "_redirects")); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Loaded %d header rules and %d redirect rules", len(h.headers), len(h.redirects))
+
+	return &http.Server{Handler: h}, nil
+}
+
+func (h *previewHandler) isFile(urlPath string) bool {
+	info, err := os.Stat(filepath.Join(h.root, filepath.FromSlash(urlPath)))
+	return err == nil && !info.IsDir()
+}
+
+func (h *previewHandler) isDir(urlPath string) bool {
+	info, err := os.Stat(filepath.Join(h.root, filepath.FromSlash(urlPath)))
+	return err == nil && info.IsDir()
+}
+
+// resolve finds the file for a clean URL, i.e. /about for about.html or about/index.html.
+// canonical is set when the URL should be redirected to its clean form instead
+func (h *previewHandler) resolve(urlPath string) (file string, canonical string) {
+	switch {
+	case path.Base(urlPath) == "index.html" && h.isFile(urlPath):
+		return "", strings.TrimSuffix(urlPath, "index.html")
+	case strings.HasSuffix(urlPath, ".html") && h.isFile(urlPath):
+		return "", strings.TrimSuffix(urlPath, ".html")
+	case strings.HasSuffix(urlPath, "/"):
+		if h.isFile(urlPath + "index.html") {
+			return urlPath + "index.html", ""
+		}
+		if trimmed := strings.TrimSuffix(urlPath, "/"); trimmed != "" && h.isFile(trimmed+".html") {
+			return "", trimmed
+		}
+	case h.isFile(urlPath):
+		return urlPath, ""
+	case h.isFile(urlPath + ".html"):
+		return urlPath + ".html", ""
+	case h.isDir(urlPath) && h.isFile(urlPath+"/index.html"):
+		return "", urlPath + "/"
+	}
+
+	return "", ""
+}
+
+func (h *previewHandler) applyHeaders(w http.ResponseWriter, urlPath string) {
+	for _, rule := range h.headers {
+		if _, ok := rule.pattern.match(urlPath); !ok {
+			continue
+		}
+
+		for _, name := range rule.detach {
+			w.Header().Del(name)
+		}
+		for _, kv := range rule.set {
+			w.Header().Add(kv[0], kv[1])
+		}
+	}
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header allows the given encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) != encoding {
+			continue
+		}
+
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+
+	return false
+}
+
+func (h *previewHandler) serveFile(w http.ResponseWriter, r *http.Request, file string, status int) {
+	fullPath := filepath.Join(h.root, filepath.FromSlash(file))
+
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if contentType == "" && strings.EqualFold(filepath.Ext(file), ".shtml") {
+		// not every system's mime table knows .shtml, but it is html with server side includes
+		contentType = "text/html; charset=utf-8"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if w.Header().Get("Cache-Control") == "" {
+		if strings.HasPrefix(contentType, "text/html") {
+			w.Header().Set("Cache-Control", htmlCacheControl)
+		} else {
+			w.Header().Set("Cache-Control", assetCacheControl)
+		}
+	}
+
+	// precompressed siblings, i.e. app.js.br and app.js.gz
+	for _, enc := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		if _, err := os.Stat(fullPath + enc.ext); err == nil && acceptsEncoding(r, enc.name) {
+			fullPath += enc.ext
+			w.Header().Set("Content-Encoding", enc.name)
+			break
+		}
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "500 internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusOK {
+		// handles conditional and range requests
+		http.ServeContent(w, r, "", info.ModTime(), f)
+		return
+	}
+
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_, _ = io.Copy(w, f)
+	}
+}
+
+// notFound serves the custom 404 page, in the same order as the dev server looks for it
+func (h *previewHandler) notFound(w http.ResponseWriter, r *http.Request) {
+	for _, page := range []string{"/404.html", "/404.shtml"} {
+		if h.isFile(page) {
+			h.serveFile(w, r, page, http.StatusNotFound)
+			return
+		}
+	}
+
+	http.Error(w, "404 not found", http.StatusNotFound)
+}
+
+func (h *previewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	urlPath := r.URL.Path
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+	// path.Clean drops the trailing slash, which matters for clean URLs
+	cleaned := path.Clean(urlPath)
+	if strings.HasSuffix(urlPath, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	urlPath = cleaned
+
+	logger.Debug("%s %s", r.Method, urlPath)
+	h.applyHeaders(w, urlPath)
+
+	// the configuration files of the host are never served
+	if urlPath == "/_headers" || urlPath == "/_redirects" {
+		h.notFound(w, r)
+		return
+	}
+
+	file, canonical := h.resolve(urlPath)
+
+	for _, rule := range h.redirects {
+		params, ok := rule.pattern.match(urlPath)
+		if !ok {
+			continue
+		}
+		// without a !, a rule is shadowed by a file that exists at the path
+		if (file != "" || canonical != "") && !rule.force {
+			continue
+		}
+
+		target := rule.target(params)
+
+		if rule.status >= 300 {
+			if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, rule.status)
+			return
+		}
+
+		// 200 rewrites and 404s serve the target in place of the requested path
+		if rewritten, _ := h.resolve(target); rewritten != "" {
+			h.serveFile(w, r, rewritten, rule.status)
+			return
+		}
+		if h.isFile(target) {
+			h.serveFile(w, r, target, rule.status)
+			return
+		}
+
+		logger.Warning("_redirects rewrites %s to %s, which does not exist", urlPath, target)
+		break
+	}
+
+	if canonical != "" {
+		if r.URL.RawQuery != "" {
+			canonical += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, canonical, http.StatusPermanentRedirect)
+		return
+	}
+
+	if file != "" {
+		h.serveFile(w, r, file, http.StatusOK)
+		return
+	}
+
+	h.notFound(w, r)
+}
@@ -0,0 +1,110 @@
+package devserver
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"sklair/logger"
+	"sklair/sklairConfig"
+	"strings"
+)
+
+type Proxy struct {
+	Prefix  string
+	Target  string
+	Handler http.Handler
+}
+
+func setHeaders(h http.Header, headers map[string]string) {
+	for k, v := range headers {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
+
+func newProxy(rule *sklairConfig.ProxyRule) (*Proxy, error) {
+	if !strings.HasPrefix(rule.Path, "/") {
+		return nil, fmt.Errorf("proxy path %q must start with /", rule.Path)
+	}
+
+	target, err := url.Parse(rule.Target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy target %q : %s", rule.Target, err.Error())
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("proxy target %q must be an absolute http(s) URL", rule.Target)
+	}
+
+	prefix := strings.TrimSuffix(rule.Path, "/")
+
+	// the prefix becomes a http.ServeMux pattern, in which {} are wildcards and whitespace separates the method,
+	// and a pattern that is not a clean path would never match, because the mux redirects to the cleaned path first
+	if strings.ContainsAny(prefix, "{} \t\r\n") {
+		return nil, fmt.Errorf("proxy path %q must not contain braces or whitespace", rule.Path)
+	}
+	if prefix != "" && path.Clean(prefix) != prefix {
+		return nil, fmt.Errorf("proxy path %q must be a clean path, i.e. %q", rule.Path, path.Clean(prefix))
+	}
+
+	// websocket upgrades are handled by ReverseProxy itself
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			if rule.StripPrefix {
+				r.Out.URL.Path = strings.TrimPrefix(r.Out.URL.Path, prefix)
+				r.Out.URL.RawPath = ""
+			}
+
+			r.SetURL(target)
+			r.SetXForwarded()
+			if !rule.ChangeOrigin {
+				r.Out.Host = r.In.Host
+			}
+
+			setHeaders(r.Out.Header, rule.Headers)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			setHeaders(resp.Header, rule.ResponseHeaders)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logger.Warning("proxy %s -> %s failed : %s", r.URL.Path, rule.Target, err.Error())
+			http.Error(w, "sklair dev server could not reach "+rule.Target, http.StatusBadGateway)
+		},
+	}
+
+	return &Proxy{Prefix: prefix, Target: rule.Target, Handler: rp}, nil
+}
+
+// NewProxies validates the proxy section of sklair.json and creates a handler for each rule
+func NewProxies(rules []*sklairConfig.ProxyRule) ([]*Proxy, error) {
+	var proxies []*Proxy
+	seen := make(map[string]bool)
+
+	for _, rule := range rules {
+		if rule == nil {
+			return nil, errors.New("empty proxy rule")
+		}
+
+		p, err := newProxy(rule)
+		if err != nil {
+			return nil, err
+		}
+		if p.Prefix == "" || p.Prefix == "/_sklair" || strings.HasPrefix(p.Prefix, "/_sklair/") {
+			return nil, fmt.Errorf("proxy path %q would shadow the dev server itself", rule.Path)
+		}
+		if seen[p.Prefix] {
+			return nil, fmt.Errorf("proxy path %q is declared more than once", rule.Path)
+		}
+		seen[p.Prefix] = true
+
+		proxies = append(proxies, p)
+	}
+
+	return proxies, nil
+}
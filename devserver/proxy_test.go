@@ -0,0 +1,209 @@
+package devserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sklair/logger"
+	"sklair/sklairConfig"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// the dev server logs through the shared logger, which is silenced here
+	logger.InitShared(logger.LevelNone)
+	os.Exit(m.Run())
+}
+
+// upstream is a stand-in backend which records the last request it received
+type upstream struct {
+	*httptest.Server
+	last *http.Request
+}
+
+func newUpstream(t *testing.T) *upstream {
+	u := &upstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.last = r
+		w.Header().Set("Server", "upstream")
+		io.WriteString(w, "upstream "+r.URL.Path)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// newDevServer starts the dev server for an empty build directory with the given proxy rules
+func newDevServer(t *testing.T, rules ...*sklairConfig.ProxyRule) *httptest.Server {
+	proxies, err := NewProxies(rules)
+	if err != nil {
+		t.Fatalf("NewProxies: %s", err)
+	}
+
+	server := httptest.NewServer(NewServer(t.TempDir(), nil, proxies).Handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, u string, headers map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestProxyPrefixMatching(t *testing.T) {
+	backend := newUpstream(t)
+	server := newDevServer(t, &sklairConfig.ProxyRule{Path: "/api/", Target: backend.URL})
+
+	tests := []struct {
+		path    string
+		proxied bool
+	}{
+		{"/api", true},
+		{"/api/", true},
+		{"/api/users?page=2", true},
+		{"/api/users/1", true},
+		{"/apiv2/users", false},
+		{"/", false},
+		{"/about.html", false},
+	}
+
+	for _, tt := range tests {
+		backend.last = nil
+		resp, _ := get(t, server.URL+tt.path, nil)
+
+		if proxied := backend.last != nil; proxied != tt.proxied {
+			t.Errorf("%s: proxied = %v, want %v", tt.path, proxied, tt.proxied)
+		}
+		if !tt.proxied && resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404 from the static handler", tt.path, resp.StatusCode)
+		}
+	}
+}
+
+func TestProxyPathRewriting(t *testing.T) {
+	backend := newUpstream(t)
+	target := backend.URL + "/v1"
+
+	tests := []struct {
+		strip bool
+		path  string
+		want  string
+	}{
+		{false, "/api/users", "/v1/api/users"},
+		{true, "/api/users", "/v1/users"},
+		{true, "/api", "/v1/"},
+		{true, "/api/users/", "/v1/users/"},
+	}
+
+	for _, tt := range tests {
+		server := newDevServer(t, &sklairConfig.ProxyRule{Path: "/api", Target: target, StripPrefix: tt.strip})
+
+		_, body := get(t, server.URL+tt.path, nil)
+		if want := "upstream " + tt.want; body != want {
+			t.Errorf("strip=%v %s: got %q, want %q", tt.strip, tt.path, body, want)
+		}
+	}
+}
+
+func TestProxyHeaders(t *testing.T) {
+	backend := newUpstream(t)
+	backendURL, _ := url.Parse(backend.URL)
+
+	for _, changeOrigin := range []bool{false, true} {
+		server := newDevServer(t, &sklairConfig.ProxyRule{
+			Path:            "/api",
+			Target:          backend.URL,
+			ChangeOrigin:    changeOrigin,
+			Headers:         map[string]string{"X-Api-Key": "secret", "Cookie": ""},
+			ResponseHeaders: map[string]string{"Access-Control-Allow-Origin": "*", "Server": ""},
+		})
+		serverURL, _ := url.Parse(server.URL)
+
+		resp, _ := get(t, server.URL+"/api/users", map[string]string{"Cookie": "session=1", "X-Other": "kept"})
+		in := backend.last
+
+		if got := in.Header.Get("X-Api-Key"); got != "secret" {
+			t.Errorf("X-Api-Key = %q, want it to be set", got)
+		}
+		if got := in.Header.Get("Cookie"); got != "" {
+			t.Errorf("Cookie = %q, want it to be removed", got)
+		}
+		if got := in.Header.Get("X-Other"); got != "kept" {
+			t.Errorf("X-Other = %q, want it to be passed through", got)
+		}
+		if got := in.Header.Get("X-Forwarded-Host"); got != serverURL.Host {
+			t.Errorf("X-Forwarded-Host = %q, want %q", got, serverURL.Host)
+		}
+
+		wantHost := serverURL.Host
+		if changeOrigin {
+			wantHost = backendURL.Host
+		}
+		if in.Host != wantHost {
+			t.Errorf("changeOrigin=%v: Host = %q, want %q", changeOrigin, in.Host, wantHost)
+		}
+
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Access-Control-Allow-Origin = %q, want it to be set", got)
+		}
+		if got := resp.Header.Get("Server"); got != "" {
+			t.Errorf("Server = %q, want it to be removed", got)
+		}
+	}
+}
+
+func TestProxyUnreachableTarget(t *testing.T) {
+	backend := newUpstream(t)
+	backend.Close()
+
+	server := newDevServer(t, &sklairConfig.ProxyRule{Path: "/api", Target: backend.URL})
+	if resp, _ := get(t, server.URL+"/api/users", nil); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
+
+func TestNewProxiesRejectsInvalidRules(t *testing.T) {
+	tests := []*sklairConfig.ProxyRule{
+		{Path: "api", Target: "http://localhost:3000"},
+		{Path: "/", Target: "http://localhost:3000"},
+		{Path: "/_sklair/ws", Target: "http://localhost:3000"},
+		{Path: "/api/{id}", Target: "http://localhost:3000"},
+		{Path: "/api v2", Target: "http://localhost:3000"},
+		{Path: "/api//v2", Target: "http://localhost:3000"},
+		{Path: "/api/../v2", Target: "http://localhost:3000"},
+		{Path: "/api", Target: "localhost:3000"},
+		{Path: "/api", Target: "ftp://localhost"},
+	}
+
+	for _, rule := range tests {
+		if _, err := NewProxies([]*sklairConfig.ProxyRule{rule}); err == nil {
+			t.Errorf("path %q target %q: expected an error", rule.Path, rule.Target)
+		}
+	}
+
+	duplicate := []*sklairConfig.ProxyRule{
+		{Path: "/api", Target: "http://localhost:3000"},
+		{Path: "/api/", Target: "http://localhost:4000"},
+	}
+	if _, err := NewProxies(duplicate); err == nil {
+		t.Error("duplicate prefixes: expected an error")
+	}
+}
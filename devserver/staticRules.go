@@ -0,0 +1,226 @@
+package devserver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pathPattern is a path from _headers or _redirects, where :name matches a single segment and * matches the rest
+type pathPattern struct {
+	re    *regexp.Regexp
+	names []string // one per capture group, "splat" for *
+}
+
+func compilePattern(pattern string) (*pathPattern, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("path %q must start with /", pattern)
+	}
+
+	p := &pathPattern{}
+	var b strings.Builder
+	b.WriteString("^")
+
+	segments := strings.Split(strings.TrimSuffix(pattern, "/"), "/")
+	for i, segment := range segments {
+		if i > 0 {
+			b.WriteString("/")
+		}
+
+		switch {
+		case segment == "*" && i == len(segments)-1:
+			b.WriteString("(.*)")
+			p.names = append(p.names, "splat")
+		case strings.HasPrefix(segment, ":"):
+			b.WriteString("([^/]+)")
+			p.names = append(p.names, segment[1:])
+		default:
+			// a * inside a segment, i.e. /blog/*.html
+			parts := strings.Split(segment, "*")
+			for j, part := range parts {
+				if j > 0 {
+					b.WriteString("(.*)")
+					p.names = append(p.names, "splat")
+				}
+				b.WriteString(regexp.QuoteMeta(part))
+			}
+		}
+	}
+
+	// /about matches /about and /about/, just like on the usual static hosts
+	b.WriteString("/?$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+
+	return p, nil
+}
+
+func (p *pathPattern) match(path string) (map[string]string, bool) {
+	m := p.re.FindStringSubmatch(path)
+	if m == nil {
+		return nil, false
+	}
+
+	params := make(map[string]string, len(p.names))
+	for i, name := range p.names {
+		params[name] = m[i+1]
+	}
+
+	return params, true
+}
+
+type headerRule struct {
+	pattern *pathPattern
+	set     [][2]string
+	detach  []string // "! Header-Name" removes a header that an earlier rule set
+}
+
+type redirectRule struct {
+	pattern *pathPattern
+	to      string
+	status  int
+	force   bool // "301!", applies even when a file exists at the path
+}
+
+func (r *redirectRule) target(params map[string]string) string {
+	// longest names first, so that :splat is not mistaken for :s followed by "plat"
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	to := r.to
+	for _, name := range names {
+		to = strings.ReplaceAll(to, ":"+name, params[name])
+	}
+	return to
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, scanner.Err()
+}
+
+// parseHeaders reads a _headers file, which consists of paths followed by indented "Name: value" lines
+func parseHeaders(path string) ([]*headerRule, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*headerRule
+	var current *headerRule
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// a line that is not indented starts a new path
+		if line[0] != ' ' && line[0] != '\t' {
+			pattern, err := compilePattern(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("_headers line %d : %s", i+1, err.Error())
+			}
+
+			current = &headerRule{pattern: pattern}
+			rules = append(rules, current)
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("_headers line %d : header without a path", i+1)
+		}
+
+		if strings.HasPrefix(trimmed, "!") {
+			current.detach = append(current.detach, strings.TrimSpace(trimmed[1:]))
+			continue
+		}
+
+		name, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("_headers line %d : expected Name: value", i+1)
+		}
+		current.set = append(current.set, [2]string{strings.TrimSpace(name), strings.TrimSpace(value)})
+	}
+
+	return rules, nil
+}
+
+// parseRedirects reads a _redirects file, which consists of "from to [status]" lines.
+// the status defaults to 301, 200 rewrites without redirecting and a trailing ! forces the rule
+func parseRedirects(path string) ([]*redirectRule, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*redirectRule
+
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("_redirects line %d : expected from and to", i+1)
+		}
+
+		pattern, err := compilePattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("_redirects line %d : %s", i+1, err.Error())
+		}
+
+		rule := &redirectRule{pattern: pattern, to: fields[1], status: 301}
+
+		if len(fields) > 2 {
+			status := fields[2]
+			if strings.HasSuffix(status, "!") {
+				rule.force = true
+				status = strings.TrimSuffix(status, "!")
+			}
+
+			rule.status, err = strconv.Atoi(status)
+			if err != nil {
+				return nil, fmt.Errorf("_redirects line %d : invalid status %q", i+1, fields[2])
+			}
+		}
+
+		switch {
+		case rule.status == 200 || rule.status == 404:
+			if !strings.HasPrefix(rule.to, "/") {
+				return nil, fmt.Errorf("_redirects line %d : sklair preview can only rewrite to local paths, not %s", i+1, rule.to)
+			}
+		case rule.status < 300 || rule.status > 399:
+			return nil, fmt.Errorf("_redirects line %d : unsupported status %d", i+1, rule.status)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -1,23 +1,29 @@
 package devserver
 
 import (
+	"context"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sklair/discovery"
+	"sklair/logger"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-func debounce(in <-chan bool, delay time.Duration) <-chan bool {
-	out := make(chan bool)
+// debounce collects the paths that changed until none have changed for the given delay, and then sends them all at once
+func debounce(in <-chan string, delay time.Duration) <-chan []string {
+	out := make(chan []string)
 
 	go func() {
 		defer close(out)
 
 		var (
 			timer   *time.Timer
-			pending bool
+			pending = make(map[string]struct{})
 		)
 
 		for {
@@ -27,12 +33,12 @@ func debounce(in <-chan bool, delay time.Duration) <-chan bool {
 			}
 
 			select {
-			case _, ok := <-in:
+			case path, ok := <-in:
 				if !ok {
 					return
 				}
 
-				pending = true
+				pending[path] = struct{}{}
 
 				if timer == nil {
 					timer = time.NewTimer(delay)
@@ -44,9 +50,15 @@ func debounce(in <-chan bool, delay time.Duration) <-chan bool {
 				}
 
 			case <-timerC:
-				if pending {
-					out <- true
-					pending = false
+				if len(pending) > 0 {
+					paths := make([]string, 0, len(pending))
+					for path := range pending {
+						paths = append(paths, path)
+					}
+					sort.Strings(paths)
+
+					out <- paths
+					pending = make(map[string]struct{})
 				}
 				timer = nil
 			}
@@ -56,28 +68,87 @@ func debounce(in <-chan bool, delay time.Duration) <-chan bool {
 	return out
 }
 
-func shouldWatch(path string) bool {
-	base := filepath.Base(path)
-	switch base {
-	case ".sklair", ".git":
-		return false
+type watchRoots struct {
+	roots    []string // absolute, none of them inside another
+	excludes []string // normalised, matched relative to the root that a path is in
+	ignored  []string // absolute directories which are never watched, such as the output directory
+}
+
+func within(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func newWatchRoots(roots []string, excludes []string, ignored []string) *watchRoots {
+	w := &watchRoots{excludes: discovery.Excludes(excludes)}
+
+	for _, dir := range ignored {
+		if abs, err := filepath.Abs(dir); err == nil {
+			w.ignored = append(w.ignored, abs)
+		}
+	}
+
+	var abs []string
+	for _, root := range roots {
+		if a, err := filepath.Abs(root); err == nil {
+			abs = append(abs, a)
+		}
 	}
 
-	return true
+	// a root inside another root is already watched by the outer one
+	for i, root := range abs {
+		nested := false
+		for j, other := range abs {
+			if i != j && within(other, root) && (root != other || j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			w.roots = append(w.roots, root)
+		}
+	}
+
+	return w
 }
 
-// track changes from the following directories:
-// - source directory (excluding components dir, if it is within the source directory)
-// OR if the components directory is within the source directory then just ONLY track the source directory anyways
-// - components directory by itself
-// from all tracked directories, output dir must be excluded along with common excluded directories
+func (w *watchRoots) shouldWatch(path string) bool {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range w.ignored {
+		if within(dir, path) {
+			return false
+		}
+	}
+
+	for _, root := range w.roots {
+		if !within(root, path) {
+			continue
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		return rel == "." || !discovery.IsExcluded(rel, w.excludes)
+	}
 
-// TODO: dir parameter removed in favour of source and components dir and excludes list (when above changes are implemented)
-// also refer to commands/serve.go for more information
-func Watch(dir string) (<-chan bool, <-chan error) {
-	events := make(chan bool)
+	return false
+}
+
+// Watch watches every root recursively, i.e. the input, components and hooks directories.
+// excludes are the gitignore-style patterns from sklair.json, which are matched relative to each root on top of the default excludes.
+// ignored directories, such as the output directory, are never watched, so that builds cannot trigger themselves.
+// roots that do not exist are skipped.
+//
+// Watch sends the paths that changed, in batches of changes that happened close together.
+// once ctx is cancelled, the watcher is closed and so are both channels.
+func Watch(ctx context.Context, roots []string, excludes []string, ignored []string) (<-chan []string, <-chan error) {
+	events := make(chan string)
 	errs := make(chan error)
 
+	w := newWatchRoots(roots, excludes, ignored)
+
 	go func() {
 		defer close(events)
 		defer close(errs)
@@ -89,49 +160,74 @@ func Watch(dir string) (<-chan bool, <-chan error) {
 		}
 		defer watcher.Close()
 
-		// recursively watch ALL subdirectories
-		err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				if shouldWatch(path) {
-					return watcher.Add(path)
-				} else {
-					return filepath.SkipDir
+		// recursively watch ALL subdirectories that are not excluded
+		addTree := func(dir string) error {
+			return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if w.shouldWatch(path) {
+						return watcher.Add(path)
+					} else {
+						return filepath.SkipDir
+					}
 				}
+
+				return nil
+			})
+		}
+
+		for _, root := range w.roots {
+			if _, err := os.Stat(root); os.IsNotExist(err) {
+				logger.Debug("not watching %s, because it does not exist", root)
+				continue
 			}
 
-			return nil
-		})
-		if err != nil {
-			errs <- err
+			logger.Debug("watching %s", root)
+			if err := addTree(root); err != nil {
+				errs <- err
+			}
 		}
 
 		for {
 			select {
+			case <-ctx.Done():
+				return
 			case e, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
 
-				// handle new directories dynamically
+				if !w.shouldWatch(e.Name) {
+					continue
+				}
+
+				// handle new directories dynamically, including everything that was created inside them already
 				if e.Op&fsnotify.Create != 0 {
-					if info, err := os.Stat(e.Name); err == nil && info.IsDir() && shouldWatch(e.Name) {
-						_ = watcher.Add(e.Name)
+					if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
+						_ = addTree(e.Name)
 					}
 				}
 
 				// we only want writes, creates and deletes
 				if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
-					events <- true
+					select {
+					case events <- e.Name:
+					case <-ctx.Done():
+						return
+					}
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
 
-				errs <- err
+				select {
+				case errs <- err:
+				case <-ctx.Done():
+					return
+				}
 			}
 		}
 	}()
@@ -9,16 +9,35 @@ import (
 
 const WSPath = "_sklair/ws"
 
+const (
+	MessageReload = "reload"
+	MessageCSS    = "css"   // only stylesheets changed, so they are swapped in place instead of reloading the page
+	MessageError  = "error" // the last build failed, shown as an overlay until the next successful build
+)
+
+// Message is sent to the browser as JSON, see ws_refresh.js
+type Message struct {
+	Type string `json:"type"`
+
+	// only set for MessageError
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
 type WS struct {
 	clients map[*websocket.Conn]struct{}
 	mu      sync.Mutex
-	Send    chan string
+	Send    chan Message
+
+	lastError *Message // sent to clients that connect while the build is still broken
+	closed    bool
 }
 
 func NewWS() *WS {
 	ws := &WS{
 		clients: make(map[*websocket.Conn]struct{}),
-		Send:    make(chan string),
+		Send:    make(chan Message),
 	}
 	go ws.run()
 	return ws
@@ -27,17 +46,44 @@ func NewWS() *WS {
 func (ws *WS) run() {
 	for msg := range ws.Send {
 		ws.mu.Lock()
+		if msg.Type == MessageError {
+			ws.lastError = &msg
+		} else {
+			ws.lastError = nil
+		}
+
 		for client := range ws.clients {
-			_ = websocket.Message.Send(client, msg)
-			logger.Debug("Sent message to client")
+			_ = websocket.JSON.Send(client, msg)
+			logger.Debug("Sent %s message to client", msg.Type)
 		}
 		ws.mu.Unlock()
 	}
 }
 
+// Close disconnects every client with a close frame, and any client that connects afterwards right away.
+// the http server does not track websocket connections, so this must happen before it is shut down
+func (ws *WS) Close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.closed = true
+	for client := range ws.clients {
+		_ = client.Close()
+		delete(ws.clients, client)
+	}
+}
+
 func (ws *WS) HandleWS(c *websocket.Conn) {
 	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		_ = c.Close()
+		return
+	}
 	ws.clients[c] = struct{}{}
+	if ws.lastError != nil {
+		_ = websocket.JSON.Send(c, *ws.lastError)
+	}
 	ws.mu.Unlock()
 
 	defer func() {
@@ -1 +1,82 @@
-new WebSocket(`ws://${ location.host }/WEBSOCKET_PATH`).onmessage = (e) => {if (e.data === "reload") location.reload();}
\ No newline at end of file
+(() => {
+    const OVERLAY_ID = "sklair-error-overlay";
+    const SCROLL_KEY = "sklair-scroll:" + location.pathname;
+
+    // restore the scroll position from before the last reload
+    const saved = sessionStorage.getItem(SCROLL_KEY);
+    if (saved) {
+        sessionStorage.removeItem(SCROLL_KEY);
+        const [x, y] = JSON.parse(saved);
+        addEventListener("load", () => scrollTo(x, y), { once: true });
+    }
+
+    const reload = () => {
+        sessionStorage.setItem(SCROLL_KEY, JSON.stringify([scrollX, scrollY]));
+        location.reload();
+    };
+
+    // re-requests every linked stylesheet with a new query string, so that the browser cannot use its cached copy
+    const swapStylesheets = () => {
+        for (const link of document.querySelectorAll('link[rel="stylesheet"][href]')) {
+            const url = new URL(link.href, location.href);
+            if (url.origin !== location.origin) continue;
+
+            url.searchParams.set("sklair", Date.now().toString());
+
+            // the old stylesheet stays until the new one has loaded, otherwise the page flashes unstyled
+            const next = link.cloneNode();
+            next.href = url.href;
+            next.addEventListener("load", () => link.remove(), { once: true });
+            next.addEventListener("error", () => next.remove(), { once: true });
+            link.after(next);
+        }
+    };
+
+    const hideError = () => document.getElementById(OVERLAY_ID)?.remove();
+
+    const showError = (msg) => {
+        hideError();
+
+        const overlay = document.createElement("div");
+        overlay.id = OVERLAY_ID;
+        overlay.style.cssText = "position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:32px;" +
+            "background:rgba(20,20,20,.92);color:#eee;font:14px/1.5 ui-monospace,monospace";
+
+        const title = document.createElement("div");
+        title.style.cssText = "color:#ff6b6b;font-size:18px;font-weight:bold;margin-bottom:8px";
+        title.textContent = "Sklair build failed";
+
+        const file = document.createElement("div");
+        file.style.cssText = "color:#8ab4f8;margin-bottom:16px";
+        file.textContent = msg.file ? (msg.line ? `${ msg.file }:${ msg.line }` : msg.file) : "";
+
+        const message = document.createElement("pre");
+        message.style.cssText = "white-space:pre-wrap;margin:0";
+        message.textContent = msg.message;
+
+        const hint = document.createElement("div");
+        hint.style.cssText = "color:#999;margin-top:16px";
+        hint.textContent = "This overlay disappears once the next build succeeds.";
+
+        overlay.append(title, file, message, hint);
+        document.body.append(overlay);
+    };
+
+    const protocol = location.protocol === "https:" ? "wss" : "ws";
+    new WebSocket(`${ protocol }://${ location.host }/WEBSOCKET_PATH`).onmessage = (e) => {
+        const msg = JSON.parse(e.data);
+
+        switch (msg.type) {
+            case "reload":
+                reload();
+                break;
+            case "css":
+                hideError();
+                swapStylesheets();
+                break;
+            case "error":
+                showError(msg);
+                break;
+        }
+    };
+})();
@@ -15,7 +15,8 @@ func DiscoverComponents(source string) (map[string]string, error) {
 	components := make(map[string]string)
 
 	for _, file := range dir {
-		if !file.IsDir() {
+		// preview fixtures (Name.preview.json) belong to a component, they are not components themselves
+		if !file.IsDir() && !strings.HasSuffix(file.Name(), ".preview.json") {
 			name := file.Name()
 			ext := filepath.Ext(name)
 
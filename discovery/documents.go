@@ -9,8 +9,9 @@ import (
 )
 
 type DocumentLists struct {
-	HtmlFiles   []string
-	StaticFiles []string
+	HtmlFiles     []string
+	MarkdownFiles []string // compiled only if markdown is enabled in sklair.json, otherwise they are static files
+	StaticFiles   []string
 }
 
 var defaultExcludes = []string{
@@ -128,6 +129,34 @@ func isExcluded(rel string, excludes []string, includes []string) bool {
 	return false
 }
 
+// Excludes normalises the given gitignore-style patterns and adds the default excludes to them,
+// which results in the same list that DiscoverDocuments uses. see IsExcluded
+func Excludes(patterns []string) []string {
+	return normaliseExcludes(append(defaultExcludes, patterns...))
+}
+
+// Patterns normalises the given gitignore-style patterns without adding the default excludes,
+// i.e. for matching paths inside of the output directory. see IsExcluded
+func Patterns(patterns []string) []string {
+	return normaliseExcludes(patterns)
+}
+
+// IsExcluded reports whether rel, which is relative to the directory being walked, matches the patterns returned by Excludes or Patterns
+func IsExcluded(rel string, patterns []string) bool {
+	excludes, includes := splitPatterns(patterns)
+	return isExcluded(rel, excludes, includes)
+}
+
+// IsHTML reports whether name has one of the extensions that are compiled as html documents
+func IsHTML(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".htm", ".html", ".shtml", ".xhtml":
+		return true
+	default:
+		return false
+	}
+}
+
 // DiscoverDocuments returns a list of all HTML and static files in the given root directory
 //
 // During discovery, excludes is a list of gitignore-style glob patterns
@@ -143,8 +172,7 @@ func isExcluded(rel string, excludes []string, includes []string) bool {
 func DiscoverDocuments(root string, excludes []string, excludeCompile []string) (*DocumentLists, error) {
 	lists := &DocumentLists{}
 
-	excludes = append(defaultExcludes, excludes...)
-	excludes = normaliseExcludes(excludes)
+	excludes = Excludes(excludes)
 	//fmt.Println(excludes)
 	excludePatterns, includePatterns := splitPatterns(excludes)
 
@@ -180,8 +208,11 @@ func DiscoverDocuments(root string, excludes []string, excludeCompile []string)
 		}
 
 		ext := filepath.Ext(strings.ToLower(info.Name()))
-		if (ext == ".htm" || ext == ".html" || ext == ".shtml" || ext == ".xhtml") && !isExcluded(relPath, excludeCompilePatterns, includeCompilePatterns) {
+		compile := !isExcluded(relPath, excludeCompilePatterns, includeCompilePatterns)
+		if IsHTML(info.Name()) && compile {
 			lists.HtmlFiles = append(lists.HtmlFiles, path)
+		} else if (ext == ".md" || ext == ".markdown") && compile {
+			lists.MarkdownFiles = append(lists.MarkdownFiles, path)
 		} else {
 			lists.StaticFiles = append(lists.StaticFiles, path)
 		}
@@ -4,12 +4,22 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sklair/sklairConfig"
 	"sort"
+	"strings"
 )
 
+const TestSuffix = "_test.lua"
+
+type Hook struct {
+	Name        string           // file name, relative to the phase directory
+	Permissions *HookPermissions // nil if the hook is not declared in hooks.json
+}
+
 type Hookset struct {
-	PreBuild  []string
-	PostBuild []string
+	PreBuild  []*Hook
+	PostBuild []*Hook
+	Page      []*Hook // ran for every document, between component expansion and head optimisation
 }
 
 func findHooks(source string) ([]string, bool, error) {
@@ -24,19 +34,25 @@ func findHooks(source string) ([]string, bool, error) {
 	var hooks []string
 
 	for _, file := range dir {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".lua" {
+		// hook tests live right next to the hooks they test, see sklair test-hooks
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".lua" && !strings.HasSuffix(file.Name(), TestSuffix) {
 			hooks = append(hooks, file.Name())
 		}
 	}
 
-	sort.Strings(hooks) // hooks MUST be executed in alphabetical order, mainly for ordering with numbers
+	sort.Strings(hooks) // without a hooks.json, hooks MUST be executed in alphabetical order, mainly for ordering with numbers
 	// eg 1-x.lua, 2-y.lua, 3-z.lua.
 	return hooks, true, nil
 }
 
-func DiscoverHooks(source string) (*Hookset, error) {
+// DiscoverHooks finds all hooks in the pre, post and page directories of source.
+//
+// If a hooks.json manifest exists in source, it is validated and used to order the hooks,
+// drop the ones that are disabled for the given profile, and attach per-hook permissions.
+func DiscoverHooks(source string, profile sklairConfig.Profile) (*Hookset, error) {
 	preDir := filepath.Join(source, "pre")
 	postDir := filepath.Join(source, "post")
+	pageDir := filepath.Join(source, "page")
 
 	pre, preExists, err := findHooks(preDir)
 	if err != nil {
@@ -46,10 +62,33 @@ func DiscoverHooks(source string) (*Hookset, error) {
 	if err != nil {
 		return nil, err
 	}
+	page, pageExists, err := findHooks(pageDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if !(preExists || postExists || pageExists) {
+		return nil, fmt.Errorf("no hooks found, none of %q, %q or %q exist", preDir, postDir, pageDir)
+	}
+
+	manifest, err := loadHooksManifest(source)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &HooksManifest{}
+	}
 
-	if !(preExists || postExists) {
-		return nil, fmt.Errorf("no hooks found, neither %q nor %q exist", preDir, postDir)
+	set := &Hookset{}
+	if set.PreBuild, err = orderHooks("pre", pre, manifest.Pre, profile); err != nil {
+		return nil, err
+	}
+	if set.PostBuild, err = orderHooks("post", post, manifest.Post, profile); err != nil {
+		return nil, err
+	}
+	if set.Page, err = orderHooks("page", page, manifest.Page, profile); err != nil {
+		return nil, err
 	}
 
-	return &Hookset{pre, post}, nil
+	return set, nil
 }
@@ -0,0 +1,191 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sklair/sklairConfig"
+	"slices"
+)
+
+const HooksManifestName = "hooks.json"
+
+// FSRoots lists every fs root that can be granted to a hook in hooks.json
+var FSRoots = []string{"cache", "project", "temp", "generated", "built"}
+
+type HookPermissions struct {
+	// FS is the list of fs roots (e.g. "cache", "built") the hook may access.
+	// nil means that every root is available, an empty list means that the fs library is unusable.
+	FS []string `json:"fs"`
+	// HTTP is the list of hosts the hook may send requests to.
+	// These are further restricted by hooks.http.allowedHosts in sklair.json.
+	// nil means that the hosts from sklair.json are used as-is.
+	HTTP []string `json:"http"`
+}
+
+type HookManifestEntry struct {
+	Name string `json:"name"`
+	// Whether the hook should run at all, defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+	// The profiles the hook runs in. If empty, the hook runs in every profile.
+	Profiles []sklairConfig.Profile `json:"profiles,omitempty"`
+	// Hooks (in the same phase) which must run before this one
+	After []string `json:"after,omitempty"`
+
+	Permissions *HookPermissions `json:"permissions,omitempty"`
+}
+
+// HooksManifest (hooks.json) optionally lives in the root of the hooks directory.
+// The order of entries within each phase is the order in which those hooks are run,
+// unless dependencies declared with "after" say otherwise.
+type HooksManifest struct {
+	Pre  []*HookManifestEntry `json:"pre,omitempty"`
+	Post []*HookManifestEntry `json:"post,omitempty"`
+	Page []*HookManifestEntry `json:"page,omitempty"`
+}
+
+func loadHooksManifest(source string) (*HooksManifest, error) {
+	data, err := os.ReadFile(filepath.Join(source, HooksManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var manifest HooksManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("could not parse %s : %s", HooksManifestName, err.Error())
+	}
+
+	return &manifest, nil
+}
+
+func (e *HookManifestEntry) runsIn(profile sklairConfig.Profile) bool {
+	if e.Enabled != nil && !*e.Enabled {
+		return false
+	}
+
+	return len(e.Profiles) == 0 || slices.Contains(e.Profiles, profile)
+}
+
+func validateEntry(phase string, e *HookManifestEntry, found []string) error {
+	if e.Name == "" {
+		return fmt.Errorf("%s: hook entry without a name", phase)
+	}
+
+	if !slices.Contains(found, e.Name) {
+		return fmt.Errorf("%s/%s: hook is declared in %s but does not exist", phase, e.Name, HooksManifestName)
+	}
+
+	for _, p := range e.Profiles {
+		if p != sklairConfig.ProfileProduction && p != sklairConfig.ProfileDevelopment {
+			return fmt.Errorf("%s/%s: unknown profile %q", phase, e.Name, p)
+		}
+	}
+
+	for _, dep := range e.After {
+		if dep == e.Name {
+			return fmt.Errorf("%s/%s: hook cannot run after itself", phase, e.Name)
+		}
+		if !slices.Contains(found, dep) {
+			return fmt.Errorf("%s/%s: depends on %q, which does not exist in the same phase", phase, e.Name, dep)
+		}
+	}
+
+	if e.Permissions != nil {
+		for _, root := range e.Permissions.FS {
+			if !slices.Contains(FSRoots, root) {
+				return fmt.Errorf("%s/%s: unknown fs root %q", phase, e.Name, root)
+			}
+		}
+	}
+
+	return nil
+}
+
+// orderHooks applies a hooks.json phase to the hooks that were found on disk.
+//
+// Hooks declared in the manifest come first in the declared order, followed by any undeclared hooks in alphabetical order.
+// That order is then adjusted (as little as possible) so that every hook runs after its dependencies.
+// Finally, hooks that are disabled for the given profile are dropped.
+func orderHooks(phase string, found []string, entries []*HookManifestEntry, profile sklairConfig.Profile) ([]*Hook, error) {
+	byName := make(map[string]*HookManifestEntry, len(entries))
+	var order []string
+
+	for _, e := range entries {
+		if err := validateEntry(phase, e, found); err != nil {
+			return nil, err
+		}
+		if _, dup := byName[e.Name]; dup {
+			return nil, fmt.Errorf("%s/%s: hook is declared more than once", phase, e.Name)
+		}
+
+		byName[e.Name] = e
+		order = append(order, e.Name)
+	}
+
+	// found is already sorted alphabetically
+	for _, name := range found {
+		if _, declared := byName[name]; !declared {
+			order = append(order, name)
+		}
+	}
+
+	// stable topological sort: repeatedly take the earliest hook whose dependencies have all been placed
+	placed := make(map[string]bool, len(order))
+	sorted := make([]string, 0, len(order))
+	for len(sorted) < len(order) {
+		progressed := false
+
+		for _, name := range order {
+			if placed[name] {
+				continue
+			}
+
+			ready := true
+			if e := byName[name]; e != nil {
+				for _, dep := range e.After {
+					if !placed[dep] {
+						ready = false
+						break
+					}
+				}
+			}
+
+			if ready {
+				placed[name] = true
+				sorted = append(sorted, name)
+				progressed = true
+				break
+			}
+		}
+
+		if !progressed {
+			var stuck []string
+			for _, name := range order {
+				if !placed[name] {
+					stuck = append(stuck, name)
+				}
+			}
+			return nil, fmt.Errorf("%s: circular dependency between hooks %v", phase, stuck)
+		}
+	}
+
+	var hooks []*Hook
+	for _, name := range sorted {
+		hook := &Hook{Name: name}
+
+		if e := byName[name]; e != nil {
+			if !e.runsIn(profile) {
+				continue
+			}
+			hook.Permissions = e.Permissions
+		}
+
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
@@ -3,11 +3,15 @@ module sklair
 go 1.25
 
 require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/andybalholm/cascadia v1.3.3
 	github.com/bmatcuk/doublestar/v4 v4.9.2
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/invopop/jsonschema v0.13.0
+	github.com/yuin/goldmark v1.8.6
 	github.com/yuin/gopher-lua v1.1.1
 	golang.org/x/net v0.49.0
+	gopkg.in/yaml.v3 v3.0.1
 	layeh.com/gopher-json v0.0.0-20201124131017-552bb3c4c3bf
 )
 
@@ -17,5 +21,4 @@ require (
 	github.com/mailru/easyjson v0.9.1 // indirect
 	github.com/wk8/go-ordered-map/v2 v2.1.8 // indirect
 	golang.org/x/sys v0.40.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -4,6 +4,7 @@ import (
 	"hash/maphash"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"golang.org/x/net/html"
 )
@@ -109,3 +110,18 @@ func RemoveAllChildren(n *html.Node) {
 		c = next
 	}
 }
+
+// ValidAttributeName follows the html spec, which allows anything in attribute names
+// except for whitespace, control characters, quotes, >, / and =
+func ValidAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune("\"'>/=", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,249 @@
+package linkChecker
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sklair/discovery"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many external links are requested at the same time
+const externalWorkers = 8
+
+// Problem is a reference that does not resolve
+type Problem struct {
+	File   string // relative to the output directory, slash-separated
+	Line   int
+	URL    string
+	Reason string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s : %s", p.File, p.Line, p.URL, p.Reason)
+}
+
+type Options struct {
+	// links to the site url are checked like root-relative links, i.e. https://example.com/about -> /about
+	SiteURL string
+
+	// whether links to other sites should be requested, which is slow and depends on the network
+	External bool
+	Timeout  time.Duration
+}
+
+type location struct {
+	file string
+	line int
+}
+
+// resolveFile finds the emitted file that a url path is served from, the same way that static hosts do,
+// i.e. /about can be about, about.html or about/index.html
+func resolveFile(files map[string]bool, urlPath string) (string, bool) {
+	p := strings.TrimPrefix(urlPath, "/")
+
+	var candidates []string
+	if p == "" || strings.HasSuffix(p, "/") {
+		candidates = []string{p + "index.html"}
+	} else {
+		candidates = []string{p, p + ".html", p + "/index.html"}
+	}
+
+	for _, candidate := range candidates {
+		if files[candidate] {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// fragmentExists reports whether a #fragment points to something, "top" always does even without an id
+func fragmentExists(doc *Document, fragment string) bool {
+	return fragment == "" || strings.EqualFold(fragment, "top") || doc.IDs[fragment]
+}
+
+// Check verifies every reference in every html file in outputDir.
+// internal references must resolve to an emitted file, and their #fragments must match an id in that file.
+// the problems are sorted by file and line
+func Check(outputDir string, opts Options) ([]*Problem, error) {
+	files := map[string]bool{}
+	docs := map[string]*Document{}
+
+	err := filepath.WalkDir(outputDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(outputDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files[rel] = true
+
+		if discovery.IsHTML(rel) {
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			docs[rel] = Scan(content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var site *url.URL
+	if opts.SiteURL != "" {
+		site, err = url.Parse(strings.TrimSuffix(opts.SiteURL, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid site url %s : %s", opts.SiteURL, err.Error())
+		}
+	}
+
+	var problems []*Problem
+	external := map[string][]location{}
+
+	for rel, doc := range docs {
+		// relative urls resolve against <base href> if there is one, just like in browsers, which ignore an invalid one
+		base := &url.URL{Path: "/" + rel}
+		if doc.Base != "" {
+			if b, err := url.Parse(doc.Base); err == nil {
+				base = base.ResolveReference(b)
+			}
+		}
+
+		for _, ref := range doc.References {
+			report := func(reason string) {
+				problems = append(problems, &Problem{File: rel, Line: ref.Line, URL: ref.URL, Reason: reason})
+			}
+
+			if ref.URL == "" {
+				if ref.Attr != "href" {
+					report("empty " + ref.Attr)
+				}
+				continue
+			}
+
+			u, err := url.Parse(ref.URL)
+			if err != nil {
+				report("invalid url")
+				continue
+			}
+
+			if u.Scheme == "" && u.Host == "" {
+				// a <base> on another site makes relative urls external, so they are classified afterwards
+				u = base.ResolveReference(u)
+			}
+
+			switch {
+			case u.Scheme == "" && u.Host == "":
+				// inside of the output
+			case u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https":
+				continue // mailto:, tel:, data: and so on
+			case site != nil && strings.EqualFold(u.Host, site.Host) && (u.Path == site.Path || strings.HasPrefix(u.Path, site.Path+"/")):
+				u = &url.URL{Path: "/" + strings.TrimPrefix(strings.TrimPrefix(u.Path, site.Path), "/"), Fragment: u.Fragment}
+			default:
+				if u.Scheme == "" {
+					u.Scheme = "https" // protocol-relative
+				}
+				u.Fragment = ""
+				external[u.String()] = append(external[u.String()], location{file: rel, line: ref.Line})
+				continue
+			}
+
+			file, ok := resolveFile(files, u.Path)
+			if !ok {
+				report("no such file in the output")
+				continue
+			}
+
+			if targetDoc, ok := docs[file]; ok && !fragmentExists(targetDoc, u.Fragment) {
+				report(fmt.Sprintf("%s has no element with the id %q", file, u.Fragment))
+			}
+		}
+	}
+
+	if opts.External && len(external) > 0 {
+		problems = append(problems, checkExternal(external, opts.Timeout)...)
+	}
+
+	slices.SortStableFunc(problems, func(a, b *Problem) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
+		}
+		return a.Line - b.Line
+	})
+
+	return problems, nil
+}
+
+// checkExternal requests every external url once. HEAD is tried first, but plenty of servers do not support it
+func checkExternal(external map[string][]location, timeout time.Duration) []*Problem {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	request := func(method string, u string) (int, error) {
+		req, err := http.NewRequest(method, u, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("User-Agent", "sklair-link-checker")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	urls := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var problems []*Problem
+
+	for range externalWorkers {
+		wg.Go(func() {
+			for u := range urls {
+				status, err := request(http.MethodHead, u)
+				if err != nil || status == http.StatusMethodNotAllowed || status == http.StatusForbidden || status == http.StatusNotImplemented {
+					status, err = request(http.MethodGet, u)
+				}
+
+				var reason string
+				switch {
+				case err != nil:
+					reason = err.Error()
+				case status >= 400:
+					reason = fmt.Sprintf("responded with %d %s", status, http.StatusText(status))
+				default:
+					continue
+				}
+
+				mu.Lock()
+				for _, loc := range external[u] {
+					problems = append(problems, &Problem{File: loc.file, Line: loc.line, URL: u, Reason: reason})
+				}
+				mu.Unlock()
+			}
+		})
+	}
+
+	for u := range external {
+		urls <- u
+	}
+	close(urls)
+	wg.Wait()
+
+	return problems
+}
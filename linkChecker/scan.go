@@ -0,0 +1,120 @@
+package linkChecker
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Reference is a url inside of a html document
+type Reference struct {
+	Line int    // line of the tag which holds the url, starting at 1
+	Tag  string // i.e. "a" or "img"
+	Attr string // i.e. "href" or "srcset"
+	URL  string
+}
+
+// Document is everything the link checker needs to know about a single html file
+type Document struct {
+	References []Reference
+	IDs        map[string]bool // every id, and every name of an <a>, which fragments can point to
+	Base       string          // the href of <base>, if there is one
+}
+
+// link relations whose href is not a page or file, but only an origin
+var originRelations = map[string]bool{
+	"preconnect":   true,
+	"dns-prefetch": true,
+}
+
+// IsRelative reports whether u depends on the location of the page that it is in, i.e. "style.css" or "../logo.png",
+// but not "/style.css", "#top", "?page=2" or "https://example.com"
+func IsRelative(u string) bool {
+	u = strings.TrimSpace(u)
+	if u == "" || strings.HasPrefix(u, "/") || strings.HasPrefix(u, "#") || strings.HasPrefix(u, "?") {
+		return false
+	}
+
+	parsed, err := url.Parse(u)
+	return err == nil && parsed.Scheme == "" && parsed.Host == ""
+}
+
+// srcsetURLs returns the url of every candidate in a srcset, e.g. "a.png 1x, b.png 2x"
+func srcsetURLs(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// Scan tokenises a html document and collects its references and ids.
+// a tokenizer is used instead of html.Parse, because the parser does not know where in the file a node came from
+func Scan(content []byte) *Document {
+	doc := &Document{IDs: map[string]bool{}}
+
+	z := html.NewTokenizer(bytes.NewReader(content))
+	line := 1
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return doc
+		}
+
+		// the raw bytes are counted after reading the token, so tokenLine is where the tag starts
+		tokenLine := line
+		line += bytes.Count(z.Raw(), []byte("\n"))
+
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := z.Token()
+		tag := token.Data
+
+		attrs := make(map[string]string, len(token.Attr))
+		for _, attr := range token.Attr {
+			attrs[attr.Key] = attr.Val
+		}
+
+		if id := attrs["id"]; id != "" {
+			doc.IDs[id] = true
+		}
+		if name := attrs["name"]; tag == "a" && name != "" {
+			doc.IDs[name] = true
+		}
+
+		if tag == "link" {
+			skip := false
+			for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+				skip = skip || originRelations[rel]
+			}
+			if skip {
+				continue
+			}
+		}
+
+		for _, attr := range token.Attr {
+			switch attr.Key {
+			case "href", "src", "poster":
+				// <base href> changes how other urls resolve, but it is not a link itself
+				if tag == "base" {
+					if attr.Key == "href" && doc.Base == "" {
+						doc.Base = strings.TrimSpace(attr.Val)
+					}
+					continue
+				}
+				doc.References = append(doc.References, Reference{Line: tokenLine, Tag: tag, Attr: attr.Key, URL: strings.TrimSpace(attr.Val)})
+			case "srcset":
+				for _, u := range srcsetURLs(attr.Val) {
+					doc.References = append(doc.References, Reference{Line: tokenLine, Tag: tag, Attr: attr.Key, URL: u})
+				}
+			}
+		}
+	}
+}
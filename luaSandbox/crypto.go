@@ -0,0 +1,66 @@
+package luaSandbox
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+func openCrypto(_ *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		funcs := make(map[string]lua.LGFunction, len(hashes)+1)
+		for name, h := range hashes {
+			funcs[name] = hashFunc(h)
+		}
+		funcs["hmac"] = cryptoHmac
+
+		mod := L.RegisterModule("crypto", funcs)
+		L.Push(mod)
+		return 0
+	}
+}
+
+// hashFunc returns the lowercase hex digest of the first argument
+func hashFunc(h func() hash.Hash) lua.LGFunction {
+	return func(L *lua.LState) int {
+		data := L.CheckString(1)
+
+		hasher := h()
+		hasher.Write([]byte(data))
+
+		L.Push(lua.LString(hex.EncodeToString(hasher.Sum(nil))))
+		return 1
+	}
+}
+
+// cryptoHmac takes an algorithm name (md5, sha1, sha256, sha512), a key and a message,
+// and returns the lowercase hex digest
+func cryptoHmac(L *lua.LState) int {
+	algo := L.CheckString(1)
+	key := L.CheckString(2)
+	msg := L.CheckString(3)
+
+	h, ok := hashes[algo]
+	if !ok {
+		L.ArgError(1, "unsupported hash algorithm "+algo)
+	}
+
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(msg))
+
+	L.Push(lua.LString(hex.EncodeToString(mac.Sum(nil))))
+	return 1
+}
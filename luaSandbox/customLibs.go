@@ -17,11 +17,18 @@ type HookMode uint8
 const (
 	HookModePre HookMode = iota
 	HookModePost
+	HookModePage
 )
 
 var customLibs = []customLuaLib{
+	{"log", openLog},
 	{"fs", openFs},
-	//{"http", openHttp},
+	{"html", openHtml},
+	{"crypto", openCrypto},
+	{"encoding", openEncoding},
+	{"time", openTime},
+	{"sklair", openSklair}, // must be opened after html, because the current page's document is exposed through it
+	{"http", openHttp},
 	{"json", func(_ *SandboxOptions) lua.LGFunction {
 		return func(L *lua.LState) int {
 			n := json.Loader(L)
@@ -70,9 +70,12 @@ func OpenSandboxedDefault(ls *lua.LState, opts *SandboxOptions) {
 		tbl := table.(*lua.LTable)
 		for _, funcName := range funcs {
 			if libName == "os" && funcName == "exit" {
+				// os.exit() only ends the current hook, not the whole process
 				tbl.RawSetString(funcName, ls.NewFunction(func(L *lua.LState) int {
 					code := L.OptInt(1, 0)
-					opts.ExitChannel <- code
+					opts.Control.Exited = true
+					opts.Control.ExitCode = code
+					opts.Control.stop(L, "os.exit(%d)", code)
 					return 0
 				}))
 
@@ -0,0 +1,77 @@
+package luaSandbox
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"html"
+	"net/url"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func openEncoding(_ *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		mod := L.RegisterModule("encoding", encodingFuncs)
+		L.Push(mod)
+		return 0
+	}
+}
+
+var encodingFuncs = map[string]lua.LGFunction{
+	"base64Encode":    encoder(base64.StdEncoding.EncodeToString),
+	"base64Decode":    decoder(base64.StdEncoding.DecodeString),
+	"base64UrlEncode": encoder(base64.RawURLEncoding.EncodeToString),
+	"base64UrlDecode": decoder(base64.RawURLEncoding.DecodeString),
+	"hexEncode":       encoder(hex.EncodeToString),
+	"hexDecode":       decoder(hex.DecodeString),
+	"urlEncode":       stringEncoder(url.QueryEscape),
+	"urlDecode":       stringDecoder(url.QueryUnescape),
+	"pathEncode":      stringEncoder(url.PathEscape),
+	"pathDecode":      stringDecoder(url.PathUnescape),
+	"htmlEscape":      stringEncoder(html.EscapeString),
+	"htmlUnescape":    stringEncoder(html.UnescapeString),
+}
+
+func encoder(f func([]byte) string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		L.Push(lua.LString(f([]byte(L.CheckString(1)))))
+		return 1
+	}
+}
+
+// decoder wraps a decoding function.
+// on success, returns the decoded string. on error, returns nil and the error message.
+func decoder(f func(string) ([]byte, error)) lua.LGFunction {
+	return func(L *lua.LState) int {
+		data, err := f(L.CheckString(1))
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+
+		L.Push(lua.LString(data))
+		return 1
+	}
+}
+
+func stringEncoder(f func(string) string) lua.LGFunction {
+	return func(L *lua.LState) int {
+		L.Push(lua.LString(f(L.CheckString(1))))
+		return 1
+	}
+}
+
+func stringDecoder(f func(string) (string, error)) lua.LGFunction {
+	return func(L *lua.LState) int {
+		data, err := f(L.CheckString(1))
+		if err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+
+		L.Push(lua.LString(data))
+		return 1
+	}
+}
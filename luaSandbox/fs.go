@@ -2,8 +2,10 @@ package luaSandbox
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	lua "github.com/yuin/gopher-lua"
@@ -16,6 +18,8 @@ type FSContext struct {
 	GeneratedDir string
 	BuiltDir     string
 	Mode         HookMode
+
+	AllowedRoots []string // e.g. "cache", "built". nil means that every root is allowed
 }
 
 func openFs(opts *SandboxOptions) lua.LGFunction {
@@ -54,6 +58,10 @@ func resolvePath(ctx *FSContext, path string, mode AccessMode) (string, error) {
 		return "", errors.New("path traversal is not allowed")
 	}
 
+	if root, _, found := strings.Cut(path, ":"); found && ctx.AllowedRoots != nil && !slices.Contains(ctx.AllowedRoots, root) {
+		return "", fmt.Errorf("this hook does not have permission to access `%s` files", root)
+	}
+
 	switch {
 	case strings.HasPrefix(path, "cache:"):
 		return filepath.Join(ctx.CacheDir, strings.TrimPrefix(path, "cache:")), nil
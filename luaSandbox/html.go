@@ -0,0 +1,515 @@
+package luaSandbox
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"sklair/htmlUtilities"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	lua "github.com/yuin/gopher-lua"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const htmlNodeTypeName = "html.node"
+
+func openHtml(_ *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		mt := L.NewTypeMetatable(htmlNodeTypeName)
+		L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), htmlNodeMethods))
+		L.SetField(mt, "__tostring", L.NewFunction(nodeRender))
+
+		mod := L.RegisterModule("html", htmlFuncs)
+		L.Push(mod)
+		return 0
+	}
+}
+
+var htmlFuncs = map[string]lua.LGFunction{
+	"parse":         htmlParse,
+	"parseFragment": htmlParseFragment,
+	"render":        nodeRender,
+	"element":       htmlElement,
+	"text":          htmlText,
+	"comment":       htmlComment,
+}
+
+var htmlNodeMethods = map[string]lua.LGFunction{
+	"query":        nodeQuery,
+	"queryAll":     nodeQueryAll,
+	"tag":          nodeTag,
+	"type":         nodeType,
+	"attr":         nodeAttr,
+	"attrs":        nodeAttrs,
+	"setAttr":      nodeSetAttr,
+	"removeAttr":   nodeRemoveAttr,
+	"text":         nodeText,
+	"setText":      nodeSetText,
+	"innerHTML":    nodeInnerHTML,
+	"setInnerHTML": nodeSetInnerHTML,
+	"parent":       nodeParent,
+	"children":     nodeChildren,
+	"append":       nodeAppend,
+	"prepend":      nodePrepend,
+	"before":       nodeBefore,
+	"after":        nodeAfter,
+	"replaceWith":  nodeReplaceWith,
+	"remove":       nodeRemove,
+	"clone":        nodeClone,
+	"render":       nodeRender,
+}
+
+// WrapHtmlNode exposes an existing node to Lua.
+// Any changes made by the hook are applied directly to the given node.
+func WrapHtmlNode(L *lua.LState, n *html.Node) lua.LValue {
+	if n == nil {
+		return lua.LNil
+	}
+
+	ud := L.NewUserData()
+	ud.Value = n
+	L.SetMetatable(ud, L.GetTypeMetatable(htmlNodeTypeName))
+	return ud
+}
+
+func checkNode(L *lua.LState, n int) *html.Node {
+	ud := L.CheckUserData(n)
+	if node, ok := ud.Value.(*html.Node); ok {
+		return node
+	}
+
+	L.ArgError(n, "html node expected")
+	return nil
+}
+
+func checkSelector(L *lua.LState, n int) cascadia.Selector {
+	sel, err := cascadia.Compile(L.CheckString(n))
+	if err != nil {
+		L.ArgError(n, "invalid selector : "+err.Error())
+	}
+	return sel
+}
+
+// contextFor returns the element which fragments inserted into n should be parsed in the context of
+func contextFor(n *html.Node) *html.Node {
+	if n != nil && n.Type == html.ElementNode {
+		return n
+	}
+	return &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+}
+
+// contains reports whether n is descendant itself or one of its ancestors
+func contains(n *html.Node, descendant *html.Node) bool {
+	for p := descendant; p != nil; p = p.Parent {
+		if p == n {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInsertable converts the arguments from position n onwards into nodes that are ready to be inserted into parent,
+// next to anchor if there is one. Strings are parsed as HTML fragments, and nodes that are already attached somewhere
+// are moved, just like the DOM does. Nodes are only detached after every argument was checked,
+// so that a rejected argument leaves the tree untouched
+func checkInsertable(L *lua.LState, n int, parent *html.Node, anchor *html.Node) []*html.Node {
+	for i := n; i <= L.GetTop(); i++ {
+		if _, ok := L.Get(i).(*lua.LUserData); !ok {
+			continue
+		}
+
+		node := checkNode(L, i)
+		switch {
+		case anchor != nil && node == anchor:
+			L.ArgError(i, "cannot insert a node next to itself")
+		case contains(node, parent):
+			L.ArgError(i, "cannot insert a node into itself or into one of its descendants")
+		}
+	}
+
+	var out []*html.Node
+	for i := n; i <= L.GetTop(); i++ {
+		switch v := L.Get(i).(type) {
+		case lua.LString:
+			nodes, err := html.ParseFragment(strings.NewReader(string(v)), contextFor(parent))
+			if err != nil {
+				L.ArgError(i, "could not parse HTML : "+err.Error())
+			}
+			out = append(out, nodes...)
+		case *lua.LUserData:
+			node := checkNode(L, i)
+			if node.Parent != nil {
+				node.Parent.RemoveChild(node)
+			}
+			out = append(out, node)
+		default:
+			L.ArgError(i, "html node or string expected")
+		}
+	}
+
+	return out
+}
+
+func pushNodeList(L *lua.LState, nodes []*html.Node) {
+	table := L.CreateTable(len(nodes), 0)
+	for i, node := range nodes {
+		table.RawSetInt(i+1, WrapHtmlNode(L, node))
+	}
+	L.Push(table)
+}
+
+// --------------------------------------------------
+// module functions
+// --------------------------------------------------
+
+// htmlParse parses a full document.
+// on success, returns the document node. on error, returns nil and the error message.
+func htmlParse(L *lua.LState) int {
+	doc, err := html.Parse(strings.NewReader(L.CheckString(1)))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(WrapHtmlNode(L, doc))
+	return 1
+}
+
+// htmlParseFragment parses a fragment of HTML in the context of an optional tag name (default "body"),
+// returning a list of nodes.
+func htmlParseFragment(L *lua.LState) int {
+	src := L.CheckString(1)
+	contextTag := L.OptString(2, "body")
+
+	context := &html.Node{Type: html.ElementNode, Data: contextTag, DataAtom: atom.Lookup([]byte(contextTag))}
+	nodes, err := html.ParseFragment(strings.NewReader(src), context)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	pushNodeList(L, nodes)
+	return 1
+}
+
+// htmlElement creates a new detached element, with an optional table of attributes
+func htmlElement(L *lua.LState) int {
+	tag := strings.ToLower(L.CheckString(1))
+	attrs := L.OptTable(2, nil)
+
+	node := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+	if attrs != nil {
+		values := map[string]string{}
+		attrs.ForEach(func(k, v lua.LValue) {
+			key, ok := k.(lua.LString)
+			if !ok {
+				L.ArgError(2, "attribute names must be strings, got "+k.Type().String())
+			}
+			if !htmlUtilities.ValidAttributeName(string(key)) {
+				L.ArgError(2, fmt.Sprintf("invalid attribute name %q", string(key)))
+			}
+			values[string(key)] = v.String()
+		})
+
+		// tables are unordered, so the attributes are sorted to keep the output the same on every build
+		keys := slices.Sorted(maps.Keys(values))
+		for _, key := range keys {
+			node.Attr = append(node.Attr, html.Attribute{Key: key, Val: values[key]})
+		}
+	}
+
+	L.Push(WrapHtmlNode(L, node))
+	return 1
+}
+
+func htmlText(L *lua.LState) int {
+	L.Push(WrapHtmlNode(L, &html.Node{Type: html.TextNode, Data: L.CheckString(1)}))
+	return 1
+}
+
+func htmlComment(L *lua.LState) int {
+	L.Push(WrapHtmlNode(L, htmlUtilities.CommentNode(L.CheckString(1))))
+	return 1
+}
+
+// --------------------------------------------------
+// node methods
+// --------------------------------------------------
+
+func nodeQuery(L *lua.LState) int {
+	node := checkNode(L, 1)
+	sel := checkSelector(L, 2)
+
+	L.Push(WrapHtmlNode(L, cascadia.Query(node, sel)))
+	return 1
+}
+
+func nodeQueryAll(L *lua.LState) int {
+	node := checkNode(L, 1)
+	sel := checkSelector(L, 2)
+
+	pushNodeList(L, cascadia.QueryAll(node, sel))
+	return 1
+}
+
+func nodeTag(L *lua.LState) int {
+	node := checkNode(L, 1)
+	if node.Type != html.ElementNode {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	L.Push(lua.LString(node.Data))
+	return 1
+}
+
+func nodeType(L *lua.LState) int {
+	node := checkNode(L, 1)
+
+	var t string
+	switch node.Type {
+	case html.DocumentNode:
+		t = "document"
+	case html.ElementNode:
+		t = "element"
+	case html.TextNode:
+		t = "text"
+	case html.CommentNode:
+		t = "comment"
+	case html.DoctypeNode:
+		t = "doctype"
+	default:
+		t = "unknown"
+	}
+
+	L.Push(lua.LString(t))
+	return 1
+}
+
+func nodeAttr(L *lua.LState) int {
+	node := checkNode(L, 1)
+	key := L.CheckString(2)
+
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			L.Push(lua.LString(attr.Val))
+			return 1
+		}
+	}
+
+	L.Push(lua.LNil)
+	return 1
+}
+
+func nodeAttrs(L *lua.LState) int {
+	node := checkNode(L, 1)
+
+	table := L.NewTable()
+	for _, attr := range node.Attr {
+		table.RawSetString(attr.Key, lua.LString(attr.Val))
+	}
+
+	L.Push(table)
+	return 1
+}
+
+func nodeSetAttr(L *lua.LState) int {
+	node := checkNode(L, 1)
+	key := L.CheckString(2)
+	val := L.CheckString(3)
+	if !htmlUtilities.ValidAttributeName(key) {
+		L.ArgError(2, fmt.Sprintf("invalid attribute name %q", key))
+	}
+
+	for i, attr := range node.Attr {
+		if attr.Key == key {
+			node.Attr[i].Val = val
+			return 0
+		}
+	}
+
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: val})
+	return 0
+}
+
+func nodeRemoveAttr(L *lua.LState) int {
+	node := checkNode(L, 1)
+	key := L.CheckString(2)
+
+	out := node.Attr[:0]
+	for _, attr := range node.Attr {
+		if attr.Key != key {
+			out = append(out, attr)
+		}
+	}
+	node.Attr = out
+
+	return 0
+}
+
+func textContent(n *html.Node, sb *strings.Builder) {
+	if n.Type == html.TextNode {
+		sb.WriteString(n.Data)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		textContent(c, sb)
+	}
+}
+
+func nodeText(L *lua.LState) int {
+	node := checkNode(L, 1)
+
+	var sb strings.Builder
+	textContent(node, &sb)
+
+	L.Push(lua.LString(sb.String()))
+	return 1
+}
+
+func nodeSetText(L *lua.LState) int {
+	node := checkNode(L, 1)
+	text := L.CheckString(2)
+
+	if node.Type == html.TextNode || node.Type == html.CommentNode {
+		node.Data = text
+		return 0
+	}
+
+	htmlUtilities.RemoveAllChildren(node)
+	node.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	return 0
+}
+
+func nodeInnerHTML(L *lua.LState) int {
+	node := checkNode(L, 1)
+
+	buf := bytes.NewBuffer(nil)
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(buf, c); err != nil {
+			L.Push(lua.LNil)
+			L.Push(lua.LString(err.Error()))
+			return 2
+		}
+	}
+
+	L.Push(lua.LString(buf.String()))
+	return 1
+}
+
+func nodeSetInnerHTML(L *lua.LState) int {
+	node := checkNode(L, 1)
+	nodes := checkInsertable(L, 2, node, nil)
+
+	htmlUtilities.RemoveAllChildren(node)
+	for _, n := range nodes {
+		node.AppendChild(n)
+	}
+	return 0
+}
+
+func nodeParent(L *lua.LState) int {
+	node := checkNode(L, 1)
+	L.Push(WrapHtmlNode(L, node.Parent))
+	return 1
+}
+
+func nodeChildren(L *lua.LState) int {
+	node := checkNode(L, 1)
+	pushNodeList(L, htmlUtilities.GetAllChildren(node))
+	return 1
+}
+
+func nodeAppend(L *lua.LState) int {
+	node := checkNode(L, 1)
+	for _, n := range checkInsertable(L, 2, node, nil) {
+		node.AppendChild(n)
+	}
+	return 0
+}
+
+func nodePrepend(L *lua.LState) int {
+	node := checkNode(L, 1)
+	nodes := checkInsertable(L, 2, node, nil)
+
+	first := node.FirstChild
+	for _, n := range nodes {
+		node.InsertBefore(n, first)
+	}
+	return 0
+}
+
+func nodeBefore(L *lua.LState) int {
+	node := checkNode(L, 1)
+	if node.Parent == nil {
+		L.RaiseError("cannot insert before a node without a parent")
+	}
+
+	for _, n := range checkInsertable(L, 2, node.Parent, node) {
+		node.Parent.InsertBefore(n, node)
+	}
+	return 0
+}
+
+func nodeAfter(L *lua.LState) int {
+	node := checkNode(L, 1)
+	if node.Parent == nil {
+		L.RaiseError("cannot insert after a node without a parent")
+	}
+
+	nodes := checkInsertable(L, 2, node.Parent, node)
+
+	next := node.NextSibling
+	for _, n := range nodes {
+		node.Parent.InsertBefore(n, next)
+	}
+	return 0
+}
+
+func nodeReplaceWith(L *lua.LState) int {
+	node := checkNode(L, 1)
+	parent := node.Parent
+	if parent == nil {
+		L.RaiseError("cannot replace a node without a parent")
+	}
+
+	for _, n := range checkInsertable(L, 2, parent, node) {
+		parent.InsertBefore(n, node)
+	}
+	parent.RemoveChild(node)
+	return 0
+}
+
+func nodeRemove(L *lua.LState) int {
+	node := checkNode(L, 1)
+	if node.Parent != nil {
+		node.Parent.RemoveChild(node)
+	}
+	return 0
+}
+
+func nodeClone(L *lua.LState) int {
+	node := checkNode(L, 1)
+	L.Push(WrapHtmlNode(L, htmlUtilities.Clone(node)))
+	return 1
+}
+
+// nodeRender renders the node (including itself) back into HTML.
+// on success, returns the HTML as a string. on error, returns nil and the error message.
+func nodeRender(L *lua.LState) int {
+	node := checkNode(L, 1)
+
+	buf := bytes.NewBuffer(nil)
+	if err := html.Render(buf, node); err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(lua.LString(buf.String()))
+	return 1
+}
@@ -1,5 +1,19 @@
 package luaSandbox
 
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sklair/sklairConfig"
+	"slices"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
 type HttpContext struct {
 	Mode HookMode
 
@@ -11,150 +25,273 @@ type HttpContext struct {
 	TimeoutMilliseconds int
 	FollowRedirects     bool
 	MaxRedirects        int
+
+	Transport http.RoundTripper // nil means http.DefaultTransport, replaced by sklair test-hooks
+}
+
+// HttpContextFromConfig converts the hooks.http section of sklair.json into a HttpContext.
+// A nil options struct results in a context which does not allow any requests.
+func HttpContextFromConfig(options *sklairConfig.HooksHttpOptions, mode HookMode) HttpContext {
+	ctx := HttpContext{Mode: mode}
+	if options == nil {
+		return ctx
+	}
+
+	ctx.HttpAllowed = options.HttpAllowed
+	ctx.AllowedHosts = options.AllowedHosts
+	for _, method := range options.AllowedMethods {
+		ctx.AllowedMethods = append(ctx.AllowedMethods, string(method))
+	}
+	ctx.MaxResponseBytes = options.MaxResponseBytes
+	ctx.TimeoutMilliseconds = options.Timeout
+	ctx.FollowRedirects = options.FollowRedirects
+	ctx.MaxRedirects = options.MaxRedirects
+
+	return ctx
+}
+
+// RestrictHosts narrows down the allowed hosts to the ones that both lists allow.
+// Wildcards are matched in both directions, so api.example.com survives against *.example.com either way round
+func (ctx *HttpContext) RestrictHosts(hosts []string) {
+	var out []string
+	add := func(host string) {
+		if !slices.Contains(out, host) {
+			out = append(out, host)
+		}
+	}
+
+	for _, host := range hosts {
+		for _, allowed := range ctx.AllowedHosts {
+			switch {
+			case hostAllowed([]string{allowed}, host):
+				add(host)
+			case hostAllowed([]string{host}, allowed):
+				add(allowed)
+			}
+		}
+	}
+	ctx.AllowedHosts = out
+}
+
+func openHttp(opts *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		contextualised := make(map[string]lua.LGFunction, len(httpFuncs))
+		for name, f := range httpFuncs {
+			contextualised[name] = f(&opts.HttpContext)
+		}
+
+		httpMod := L.RegisterModule("http", contextualised)
+		L.Push(httpMod)
+		return 0
+	}
+}
+
+type lFuncWithHttpContext func(*HttpContext) lua.LGFunction
+
+var httpFuncs = map[string]lFuncWithHttpContext{
+	"request": httpRequest,
+	"get":     httpGet,
+	"post":    httpPost,
+}
+
+func hostAllowed(allowedHosts []string, host string) bool {
+	host = strings.ToLower(host)
+
+	for _, allowed := range allowedHosts {
+		allowed = strings.ToLower(allowed)
+
+		if allowed == host {
+			return true
+		}
+		// *.example.com allows any subdomain of example.com, but not example.com itself
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+
+	return false
 }
 
-//
-//func openHttp(opts *SandboxOptions) lua.LGFunction {
-//	return func(L *lua.LState) int {
-//		contextualised := make(map[string]lua.LGFunction, len(fsFuncs))
-//		for name, f := range httpFuncs {
-//			contextualised[name] = f(&opts.FSContext)
-//		}
-//
-//		fsMod := L.RegisterModule("http", contextualised)
-//		L.Push(fsMod)
-//		return 0
-//	}
-//}
-//
-//type lFuncWithHttpContext func(*FSContext) lua.LGFunction
-//
-//var httpFuncs = map[string]lFuncWithHttpContext{
-//	"read":    readFile,
-//	"write":   writeFile,
-//	"scandir": scanDir,
-//}
-//
-//func _resolvePath(ctx *FSContext, path string, mode AccessMode) (string, error) {
-//	// TODO: what if theres a symlink in the parent and suddenly were exposed now?
-//	// yikes, but good for now because hooks are assumed trusted for now
-//	// TODO: later use filepath.Clean, filepath.IsAbs, filepath.Rel for full canonical validation against the above issue
-//	if strings.Contains(path, "..") && !(strings.Count(path, "..") == 1 && strings.HasPrefix(path, "project:../")) {
-//		return "", errors.New("path traversal is not allowed")
-//	}
-//
-//	switch {
-//	case strings.HasPrefix(path, "cache:"):
-//		return filepath.Join(ctx.CacheDir, strings.TrimPrefix(path, "cache:")), nil
-//
-//	case strings.HasPrefix(path, "project:"):
-//		if mode != AccessModeRead {
-//			return "", errors.New("project files are read-only")
-//		}
-//		return filepath.Join(ctx.ProjectDir, strings.TrimPrefix(path, "project:")), nil
-//
-//	case strings.HasPrefix(path, "temp:"):
-//		return filepath.Join(ctx.TempDir, strings.TrimPrefix(path, "temp:")), nil
-//
-//	case strings.HasPrefix(path, "generated:"):
-//		return filepath.Join(ctx.GeneratedDir, strings.TrimPrefix(path, "generated:")), nil
-//
-//	case strings.HasPrefix(path, "built:"):
-//		if ctx.Mode != HookModePost {
-//			return "", errors.New("built files are only available in post-build hooks")
-//		}
-//		return filepath.Join(ctx.BuiltDir, strings.TrimPrefix(path, "built:")), nil
-//	}
-//
-//	return "", errors.New("path must start with `cache`, `project`, `temporary`, `generated`, or `built`, followed by a colon and a relative path")
-//}
-//
-//// readFile reads the contents of a file specified by the first argument and returns the data and a potential error.
-//// on success, returns the data as a string. on error, returns nil and the error message.
-//func _readFile(ctx *FSContext) lua.LGFunction {
-//	return func(L *lua.LState) int {
-//		name := L.CheckString(1)
-//
-//		path, err := resolvePath(ctx, name, AccessModeRead)
-//		if err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		data, err := os.ReadFile(path)
-//		if err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		L.Push(lua.LString(data))
-//		return 1
-//	}
-//}
-//
-//// writeFile writes the contents of the second argument to the file specified by the first argument.
-//// on success, returns true. on error, returns the nil and the error message.
-//func _writeFile(ctx *FSContext) lua.LGFunction {
-//	return func(L *lua.LState) int {
-//		name := L.CheckString(1)
-//		data := L.CheckString(2)
-//
-//		path, err := resolvePath(ctx, name, AccessModeWrite)
-//		if err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		dir := filepath.Dir(path)
-//		if err := os.MkdirAll(dir, 0755); err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		L.Push(lua.LTrue)
-//		return 1
-//	}
-//}
-//
-//func _scanDir(ctx *FSContext) lua.LGFunction {
-//	return func(L *lua.LState) int {
-//		name := L.CheckString(1)
-//
-//		path, err := resolvePath(ctx, name, AccessModeRead)
-//		if err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		entries, err := os.ReadDir(path)
-//		if err != nil {
-//			L.Push(lua.LNil)
-//			L.Push(lua.LString(err.Error()))
-//			return 2
-//		}
-//
-//		table := L.NewTable()
-//
-//		for i, entry := range entries {
-//			obj := L.NewTable()
-//			obj.RawSetString("name", lua.LString(entry.Name()))
-//			obj.RawSetString("isDir", lua.LBool(entry.IsDir()))
-//
-//			table.RawSetInt(i+1, obj)
-//		}
-//
-//		L.Push(table)
-//		return 1
-//	}
-//}
+func checkURL(ctx *HttpContext, u *url.URL) error {
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !ctx.HttpAllowed {
+			return errors.New("plain http requests are not allowed, use https or enable httpAllowed in sklair.json")
+		}
+	default:
+		return fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	if !hostAllowed(ctx.AllowedHosts, u.Hostname()) {
+		return fmt.Errorf("host %q is not in the allowed hosts for this hook", u.Hostname())
+	}
+
+	return nil
+}
+
+func checkMethod(ctx *HttpContext, method string) error {
+	allowed := ctx.AllowedMethods
+	if len(allowed) == 0 {
+		allowed = []string{http.MethodGet, http.MethodHead}
+	}
+
+	if !slices.Contains(allowed, method) {
+		return fmt.Errorf("method %s is not allowed", method)
+	}
+
+	return nil
+}
+
+func newClient(ctx *HttpContext) *http.Client {
+	// a zero timeout would wait forever, and a zero redirect limit would stop at the first redirect
+	defaults := sklairConfig.DefaultConfig.Hooks.Http
+
+	timeout := time.Duration(ctx.TimeoutMilliseconds) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Duration(defaults.Timeout) * time.Millisecond
+	}
+
+	maxRedirects := ctx.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaults.MaxRedirects
+	}
+
+	return &http.Client{
+		Transport: ctx.Transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !ctx.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+
+			// a redirect must not be a way of escaping the allowed hosts
+			return checkURL(ctx, req.URL)
+		},
+	}
+}
+
+// doRequest performs the request and pushes the response table, or nil and an error message
+func doRequest(L *lua.LState, ctx *HttpContext, method string, rawURL string, headers *lua.LTable, body string) int {
+	fail := func(err error) int {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	// page hooks run once for every document, so a request in one of them would be repeated for every page
+	if ctx.Mode == HookModePage {
+		return fail(errors.New("http requests are not allowed in page hooks, make them in a pre-build hook and share the result through the cache: root"))
+	}
+
+	method = strings.ToUpper(method)
+	if err := checkMethod(ctx, method); err != nil {
+		return fail(err)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fail(err)
+	}
+	if err := checkURL(ctx, u); err != nil {
+		return fail(err)
+	}
+
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, u.String(), bodyReader)
+	if err != nil {
+		return fail(err)
+	}
+
+	if headers != nil {
+		headers.ForEach(func(k, v lua.LValue) {
+			req.Header.Set(k.String(), v.String())
+		})
+	}
+
+	resp, err := newClient(ctx).Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	var reader io.Reader = resp.Body
+	if ctx.MaxResponseBytes > 0 {
+		reader = io.LimitReader(resp.Body, ctx.MaxResponseBytes+1)
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fail(err)
+	}
+	if ctx.MaxResponseBytes > 0 && int64(len(data)) > ctx.MaxResponseBytes {
+		return fail(fmt.Errorf("response exceeded the maximum size of %d bytes", ctx.MaxResponseBytes))
+	}
+
+	// every header is a list, because headers such as set-cookie can be sent more than once
+	respHeaders := L.NewTable()
+	for key, values := range resp.Header {
+		list := L.CreateTable(len(values), 0)
+		for _, v := range values {
+			list.Append(lua.LString(v))
+		}
+		respHeaders.RawSetString(strings.ToLower(key), list)
+	}
+
+	result := L.NewTable()
+	result.RawSetString("status", lua.LNumber(resp.StatusCode))
+	result.RawSetString("ok", lua.LBool(resp.StatusCode >= 200 && resp.StatusCode < 300))
+	result.RawSetString("headers", respHeaders)
+	result.RawSetString("body", lua.LString(data))
+
+	L.Push(result)
+	return 1
+}
+
+// httpRequest takes a table with the fields url, method (default GET), headers and body.
+// on success, returns a table with status, ok, headers (each a list of values) and body. on error, returns nil and the error message.
+func httpRequest(ctx *HttpContext) lua.LGFunction {
+	return func(L *lua.LState) int {
+		opts := L.CheckTable(1)
+
+		rawURL, ok := opts.RawGetString("url").(lua.LString)
+		if !ok {
+			L.ArgError(1, "url must be a string")
+		}
+
+		method := http.MethodGet
+		if m, ok := opts.RawGetString("method").(lua.LString); ok {
+			method = string(m)
+		}
+
+		headers, _ := opts.RawGetString("headers").(*lua.LTable)
+
+		body := ""
+		if b, ok := opts.RawGetString("body").(lua.LString); ok {
+			body = string(b)
+		}
+
+		return doRequest(L, ctx, method, string(rawURL), headers, body)
+	}
+}
+
+// httpGet is a shorthand for http.request{method = "GET", url = url, headers = headers}
+func httpGet(ctx *HttpContext) lua.LGFunction {
+	return func(L *lua.LState) int {
+		return doRequest(L, ctx, http.MethodGet, L.CheckString(1), L.OptTable(2, nil), "")
+	}
+}
+
+// httpPost is a shorthand for http.request{method = "POST", url = url, body = body, headers = headers}
+func httpPost(ctx *HttpContext) lua.LGFunction {
+	return func(L *lua.LState) int {
+		return doRequest(L, ctx, http.MethodPost, L.CheckString(1), L.OptTable(3, nil), L.CheckString(2))
+	}
+}
@@ -0,0 +1,39 @@
+package luaSandbox
+
+import (
+	"sklair/logger"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func openLog(opts *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		mod := L.RegisterModule("log", map[string]lua.LGFunction{
+			"debug": logFunc(opts, logger.Debug, nil),
+			"info":  logFunc(opts, logger.Info, nil),
+			"warn":  logFunc(opts, logger.Warning, &opts.Control.Warnings),
+			"error": logFunc(opts, logger.Error, &opts.Control.Errors),
+		})
+		L.Push(mod)
+		return 0
+	}
+}
+
+// logFunc joins all arguments with tabs (just like print does) and sends them to the shared logger,
+// prefixed with the name of the hook. counter, if not nil, is incremented on every call.
+func logFunc(opts *SandboxOptions, log func(format string, args ...any), counter *int) lua.LGFunction {
+	return func(L *lua.LState) int {
+		parts := make([]string, 0, L.GetTop())
+		for i := 1; i <= L.GetTop(); i++ {
+			parts = append(parts, L.ToStringMeta(L.Get(i)).String())
+		}
+
+		if counter != nil {
+			*counter++
+		}
+
+		log("[%s] %s", opts.HookName, strings.Join(parts, "\t"))
+		return 0
+	}
+}
@@ -1,15 +1,61 @@
 package luaSandbox
 
-import lua "github.com/yuin/gopher-lua"
+import (
+	"context"
+
+	lua "github.com/yuin/gopher-lua"
+	"golang.org/x/net/html"
+)
+
+// PageContext is the document currently being compiled, only available to page hooks
+type PageContext struct {
+	Path     string // relative to the input directory, slash-separated
+	Document *html.Node
+}
+
+// HookControl is how a hook tells whoever is running it to stop early, and what it logged.
+// It is written to by os.exit(), sklair.abort(), sklair.skipRemaining() and the log library.
+type HookControl struct {
+	Exited   bool // os.exit() was called, ExitCode holds the code
+	ExitCode int
+
+	Aborted      bool // sklair.abort() was called, the build must fail
+	AbortMessage string
+
+	SkipRemaining bool // sklair.skipRemaining() was called, no further hooks in this phase should run
+
+	Warnings int // number of log.warn() calls
+	Errors   int // number of log.error() calls
+
+	cancel context.CancelFunc // cancels the context of the hook's Lua state
+}
+
+// stop ends the hook in a way that pcall() cannot catch.
+// the raised error unwinds the stack right away, and because the context of the Lua state is cancelled,
+// the VM raises another error on the next instruction if a pcall() caught the first one
+func (c *HookControl) stop(L *lua.LState, format string, args ...any) {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	L.RaiseError(format, args...)
+}
 
 type SandboxOptions struct {
-	ExitChannel chan int
+	HookName    string // e.g. "pre/1-hello.lua", used to prefix log messages
+	Control     *HookControl
 	FSContext   FSContext
+	HttpContext HttpContext
+	BuildInfo   *BuildInfo
+	Page        *PageContext
 }
 
 // NewSandbox creates a new Lua state with default Lua libraries opened but cleaned or modified to create a sandboxed environment.
 // It also loads our own custom libraries.
 func NewSandbox(options SandboxOptions) *lua.LState {
+	if options.Control == nil {
+		options.Control = &HookControl{}
+	}
+
 	L := lua.NewState(lua.Options{
 		RegistrySize:        128,
 		RegistryMaxSize:     512,
@@ -17,7 +63,11 @@ func NewSandbox(options SandboxOptions) *lua.LState {
 		IncludeGoStackTrace: false,
 		//MinimizeStackMemory: false,
 	})
-	
+
+	ctx, cancel := context.WithCancel(context.Background())
+	L.SetContext(ctx)
+	options.Control.cancel = cancel
+
 	OpenSandboxedDefault(L, &options)
 	OpenSandboxedCustom(L, &options)
 
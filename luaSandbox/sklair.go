@@ -0,0 +1,139 @@
+package luaSandbox
+
+import (
+	"encoding/json"
+	"sklair/constants"
+	"sklair/sklairConfig"
+
+	lua "github.com/yuin/gopher-lua"
+	luaJson "layeh.com/gopher-json"
+)
+
+// OutputFile describes a single file emitted by the build.
+// Both paths are relative and slash-separated, so that they can be directly used with the fs library,
+// e.g. fs.read("built:" .. file.output)
+// Source is empty for files that are generated without a template, such as sitemap.xml
+type OutputFile struct {
+	Source string
+	Output string
+	Kind   string // "html", "markdown", "generated" or "static"
+}
+
+// BuildInfo is exposed to hooks as the `sklair` global
+type BuildInfo struct {
+	Config  *sklairConfig.ProjectConfig
+	Profile sklairConfig.Profile
+
+	HtmlFiles     []string
+	MarkdownFiles []string
+	StaticFiles   []string
+	Components    map[string]string // lowercase component name -> file name
+	Data          map[string]any    // the data files, see building/siteData
+
+	Outputs []OutputFile // only populated for post-build hooks
+}
+
+func stringList(L *lua.LState, items []string) *lua.LTable {
+	table := L.CreateTable(len(items), 0)
+	for i, item := range items {
+		table.RawSetInt(i+1, lua.LString(item))
+	}
+	return table
+}
+
+// luaValue converts v into lua tables by round tripping it through json
+func luaValue(L *lua.LState, v any) (lua.LValue, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return lua.LNil, err
+	}
+	return luaJson.Decode(L, encoded)
+}
+
+func openSklair(opts *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		mod := L.NewTable()
+		mod.RawSetString("version", lua.LString(constants.Version))
+		mod.RawSetString("abort", L.NewFunction(sklairAbort(opts.Control)))
+		mod.RawSetString("skipRemaining", L.NewFunction(sklairSkipRemaining(opts.Control)))
+
+		info := opts.BuildInfo
+		if info == nil {
+			L.SetGlobal("sklair", mod)
+			return 0
+		}
+
+		mod.RawSetString("profile", lua.LString(info.Profile))
+
+		// the simplest way to get a faithful copy of sklair.json is to round trip it through json,
+		// which also means that field names are identical to the ones the user wrote
+		config := lua.LValue(lua.LNil)
+		if info.Config != nil {
+			var err error
+			config, err = luaValue(L, info.Config)
+			if err != nil {
+				L.RaiseError("could not decode project configuration : %s", err.Error())
+			}
+		}
+		mod.RawSetString("config", config)
+
+		// every hook decodes its own copy, so a hook can never change the data that pages or other hooks see
+		data, err := luaValue(L, info.Data)
+		if err != nil {
+			L.RaiseError("could not decode data files : %s", err.Error())
+		}
+		mod.RawSetString("data", data)
+
+		files := L.NewTable()
+		files.RawSetString("html", stringList(L, info.HtmlFiles))
+		files.RawSetString("markdown", stringList(L, info.MarkdownFiles))
+		files.RawSetString("static", stringList(L, info.StaticFiles))
+		mod.RawSetString("files", files)
+
+		components := L.NewTable()
+		for name, fileName := range info.Components {
+			components.RawSetString(name, lua.LString(fileName))
+		}
+		mod.RawSetString("components", components)
+
+		if opts.Page != nil {
+			page := L.NewTable()
+			page.RawSetString("path", lua.LString(opts.Page.Path))
+			page.RawSetString("document", WrapHtmlNode(L, opts.Page.Document))
+			mod.RawSetString("page", page)
+		}
+
+		if opts.FSContext.Mode == HookModePost {
+			outputs := L.CreateTable(len(info.Outputs), 0)
+			for i, out := range info.Outputs {
+				obj := L.NewTable()
+				obj.RawSetString("source", lua.LString(out.Source))
+				obj.RawSetString("output", lua.LString(out.Output))
+				obj.RawSetString("kind", lua.LString(out.Kind))
+				outputs.RawSetInt(i+1, obj)
+			}
+			mod.RawSetString("outputs", outputs)
+		}
+
+		L.SetGlobal("sklair", mod)
+		return 0
+	}
+}
+
+// sklairAbort immediately stops the current hook and fails the build with the given message
+func sklairAbort(control *HookControl) lua.LGFunction {
+	return func(L *lua.LState) int {
+		control.Aborted = true
+		control.AbortMessage = L.OptString(1, "no reason given")
+		control.stop(L, "sklair.abort(%q)", control.AbortMessage)
+		return 0
+	}
+}
+
+// sklairSkipRemaining lets the current hook finish, but prevents any later hooks in the same phase from running
+func sklairSkipRemaining(control *HookControl) lua.LGFunction {
+	return func(L *lua.LState) int {
+		control.SkipRemaining = true
+		return 0
+	}
+}
@@ -0,0 +1,96 @@
+package luaSandbox
+
+import (
+	"math"
+	"time"
+	_ "time/tzdata" // hooks must behave the same on every machine, even ones without a timezone database
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// timestamps are passed to and from Lua as (possibly fractional) unix seconds, just like os.time()
+
+var namedLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"DateTime":    time.DateTime,
+	"DateOnly":    time.DateOnly,
+	"TimeOnly":    time.TimeOnly,
+	"Kitchen":     time.Kitchen,
+}
+
+func openTime(_ *SandboxOptions) lua.LGFunction {
+	return func(L *lua.LState) int {
+		mod := L.RegisterModule("time", timeFuncs)
+		L.Push(mod)
+		return 0
+	}
+}
+
+var timeFuncs = map[string]lua.LGFunction{
+	"now":    timeNow,
+	"format": timeFormat,
+	"parse":  timeParse,
+}
+
+// layoutOf resolves either a named layout (e.g. "RFC3339") or a Go reference layout
+func layoutOf(L *lua.LState, n int) string {
+	layout := L.OptString(n, "RFC3339")
+	if named, ok := namedLayouts[layout]; ok {
+		return named
+	}
+	return layout
+}
+
+func locationOf(L *lua.LState, n int) *time.Location {
+	loc, err := time.LoadLocation(L.OptString(n, "UTC"))
+	if err != nil {
+		L.ArgError(n, "unknown timezone : "+err.Error())
+	}
+	return loc
+}
+
+func toSeconds(t time.Time) lua.LNumber {
+	return lua.LNumber(float64(t.UnixNano()) / float64(time.Second))
+}
+
+func timeNow(L *lua.LState) int {
+	L.Push(toSeconds(time.Now()))
+	return 1
+}
+
+// timeFormat takes unix seconds, an optional layout (default RFC3339) and an optional timezone (default UTC)
+func timeFormat(L *lua.LState) int {
+	seconds := float64(L.CheckNumber(1))
+	layout := layoutOf(L, 2)
+	loc := locationOf(L, 3)
+
+	whole, frac := math.Modf(seconds)
+	t := time.Unix(int64(whole), int64(frac*float64(time.Second))).In(loc)
+
+	L.Push(lua.LString(t.Format(layout)))
+	return 1
+}
+
+// timeParse takes a string, an optional layout (default RFC3339) and an optional timezone (default UTC),
+// which is used when the string itself does not contain one.
+// on success, returns unix seconds. on error, returns nil and the error message.
+func timeParse(L *lua.LState) int {
+	value := L.CheckString(1)
+	layout := layoutOf(L, 2)
+	loc := locationOf(L, 3)
+
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(toSeconds(t))
+	return 1
+}
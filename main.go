@@ -78,5 +78,5 @@ func run() int {
 
 	// TODO: set up the sklair dir inside the users home directory here along with the default app config
 
-	return cmd.Run(args[1:])
+	return reg.Execute(cmd, args[1:])
 }
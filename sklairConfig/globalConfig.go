@@ -15,11 +15,21 @@ var defaultGlobalConfig = GlobalConfig{
 	CheckForUpdates: true,
 }
 
-func GlobalConfigPath() (string, error) {
+// GlobalDir is the per-user sklair directory, ~/.sklair
+func GlobalDir() (string, error) {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
 
-	return filepath.Join(home, ".sklair/config.json"), nil
+	return filepath.Join(home, ".sklair"), nil
+}
+
+func GlobalConfigPath() (string, error) {
+	dir, err := GlobalDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "config.json"), nil
 }
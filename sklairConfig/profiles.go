@@ -0,0 +1,12 @@
+package sklairConfig
+
+// Profile describes the context a build is running in.
+// Hooks can use it to behave differently during development and for production builds.
+type Profile string
+
+const (
+	// ProfileProduction is used by `sklair build`
+	ProfileProduction Profile = "production"
+	// ProfileDevelopment is used by `sklair serve`
+	ProfileDevelopment Profile = "development"
+)
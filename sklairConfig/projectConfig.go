@@ -52,22 +52,134 @@ type HooksHttpOptions struct {
 
 	// The maximum size of a response that can be received from a hook, in bytes.
 	MaxResponseBytes int64 `json:"maxResponseBytes,omitempty" jsonschema:"title=Maximum response size"`
-	// The maximum time in milliseconds that a HTTP(s) request within a hook can take to respond.
+	// The maximum time in milliseconds that a HTTP(s) request within a hook can take to respond, 5 seconds if not set.
 	Timeout int `json:"timeout,omitempty" jsonschema:"title=Timeout"`
 	// Whether HTTP(s) redirects should be followed within a hook.
 	FollowRedirects bool `json:"followRedirects,omitempty" jsonschema:"title=Follow redirects"`
-	// The maximum number of redirects that can be followed by a HTTP(s) request within a hook.
+	// The maximum number of redirects that can be followed by a HTTP(s) request within a hook, 5 if not set.
 	MaxRedirects int `json:"maxRedirects,omitempty" jsonschema:"title=Maximum redirects"`
 }
 
 type Hooks struct {
 	// Whether sandboxed Lua pre- / post-build hooks should be executed.
 	Enabled bool `json:"enabled,omitempty" jsonschema:"title=Enable hooks"`
-	// The directory where pre- / post-build hooks are stored. The directory must contain the directories "pre" and "post".
+	// The directory where hooks are stored. The directory must contain at least one of the directories "pre", "post" and "page".
 	Path string `json:"path,omitempty" jsonschema:"title=Hooks directory"`
 
 	// HTTP(s) request options for pre- / post-build hooks.
 	Http *HooksHttpOptions `json:"http,omitempty" jsonschema:"title=HTTP options"`
+
+	// Whether the build should fail when a hook logs an error using log.error().
+	FailOnError bool `json:"failOnError,omitempty" jsonschema:"title=Fail on logged errors"`
+}
+
+type Markdown struct {
+	// Whether Markdown (.md) files in the input directory should be compiled into HTML pages.
+	// When disabled, they are copied to the output directory as-is.
+	Enabled bool `json:"enabled,omitempty" jsonschema:"title=Enabled"`
+	// The layout component that Markdown pages are wrapped in, unless their front matter declares a different one.
+	// The rendered Markdown replaces the <!-- sklair:content --> comment of the layout.
+	Layout string `json:"layout,omitempty" jsonschema:"title=Default layout"`
+}
+
+type CollectionPagination struct {
+	// The number of items on each page.
+	Size int `json:"size" jsonschema:"title=Page size,required,minimum=1"`
+	// The component that every page of the list is generated from. It can loop over pagination.items.
+	Template string `json:"template" jsonschema:"title=Template component,required"`
+	// The output path of every page, e.g. "blog/page/{{ pagination.number }}.html".
+	Path string `json:"path" jsonschema:"title=Output path,required"`
+	// The output path of the first page, e.g. "blog/index.html". Defaults to the output path.
+	FirstPath string `json:"firstPath,omitempty" jsonschema:"title=Output path of the first page"`
+}
+
+type CollectionTags struct {
+	// The component that the page of every tag is generated from. It can use tag.name and loop over tag.items.
+	Template string `json:"template" jsonschema:"title=Template component,required"`
+	// The output path of every tag page, e.g. "blog/tags/{{ tag.slug }}.html".
+	Path string `json:"path" jsonschema:"title=Output path,required"`
+}
+
+type Collection struct {
+	// The directory, relative to the input directory, whose pages make up the collection.
+	// Index pages directly inside of it are left out, because they usually list the collection.
+	Source string `json:"source,omitempty" jsonschema:"title=Source directory,oneof_required=source"`
+	// The data file whose list makes up the collection, as a dotted path such as "releases" for data/releases.json.
+	Data string `json:"data,omitempty" jsonschema:"title=Data list,oneof_required=data"`
+
+	// The variable that items are sorted by, e.g. "date" or "title". Items keep their original order if it is empty.
+	SortBy string `json:"sortBy,omitempty" jsonschema:"title=Sort by"`
+	// Whether items are sorted from the highest to the lowest value, i.e. newest first.
+	Reverse bool `json:"reverse,omitempty" jsonschema:"title=Reverse order"`
+
+	// The component that a page is generated from for every item of a data collection. It can use the item as item.
+	Template string `json:"template,omitempty" jsonschema:"title=Item template component"`
+	// The output path of every generated item page, e.g. "changelog/{{ item.version }}.html".
+	Path string `json:"path,omitempty" jsonschema:"title=Item output path"`
+
+	// Splits the collection into list pages of a fixed size.
+	Paginate *CollectionPagination `json:"paginate,omitempty" jsonschema:"title=Pagination"`
+	// Generates a page for every value of the tags variable of the items.
+	Tags *CollectionTags `json:"tags,omitempty" jsonschema:"title=Tag pages"`
+}
+
+type SitemapRule struct {
+	// A gitignore-style glob pattern that is matched against the output path of each page, e.g. "blog/".
+	Path string `json:"path" jsonschema:"title=Path pattern,required"`
+	// How often the matching pages are expected to change.
+	ChangeFreq string `json:"changefreq,omitempty" jsonschema:"title=Change frequency,enum=always,enum=hourly,enum=daily,enum=weekly,enum=monthly,enum=yearly,enum=never"`
+	// The priority of the matching pages relative to the other pages of the site, from 0.0 to 1.0.
+	Priority *float64 `json:"priority,omitempty" jsonschema:"title=Priority,minimum=0,maximum=1"`
+}
+
+type Sitemap struct {
+	// Whether a sitemap.xml of every compiled page, and a robots.txt that references it, should be generated.
+	// Pages with <meta name="robots" content="noindex"> are left out. The input directory may provide its own robots.txt instead.
+	Enabled bool `json:"enabled,omitempty" jsonschema:"title=Enabled"`
+	// A list of gitignore-style glob patterns of output paths which are left out of the sitemap, e.g. "drafts/".
+	Exclude []string `json:"exclude,omitempty" jsonschema:"title=Exclude patterns"`
+	// Change frequency and priority of pages. The first rule whose path matches a page is used.
+	Rules []*SitemapRule `json:"rules,omitempty" jsonschema:"title=Rules"`
+}
+
+type Feed struct {
+	// The collection whose items become the entries of the feed. Items of data collections need an item template, so that they have a page.
+	Collection string `json:"collection,omitempty" jsonschema:"title=Collection,oneof_required=collection"`
+	// The directory, relative to the input directory, whose pages become the entries of the feed.
+	Source string `json:"source,omitempty" jsonschema:"title=Source directory,oneof_required=source"`
+
+	// The title of the feed.
+	Title string `json:"title" jsonschema:"title=Title,required"`
+	// A short description of the feed.
+	Description string `json:"description,omitempty" jsonschema:"title=Description"`
+	// The author of every entry that does not declare its own author in its front matter.
+	Author string `json:"author,omitempty" jsonschema:"title=Author"`
+	// The most entries that the feed contains, newest first. Defaults to 20.
+	Limit int `json:"limit,omitempty" jsonschema:"title=Entry limit,minimum=1"`
+
+	// The output path of the RSS 2.0 feed, e.g. "blog/rss.xml".
+	RSS string `json:"rss,omitempty" jsonschema:"title=RSS output path"`
+	// The output path of the Atom feed, e.g. "blog/atom.xml".
+	Atom string `json:"atom,omitempty" jsonschema:"title=Atom output path"`
+	// The output path of the JSON Feed, e.g. "blog/feed.json".
+	JSON string `json:"json,omitempty" jsonschema:"title=JSON Feed output path"`
+}
+
+type ProxyRule struct {
+	// The path prefix whose requests are forwarded, e.g. "/api".
+	Path string `json:"path" jsonschema:"title=Path prefix,required"`
+	// The upstream URL that requests are forwarded to, e.g. "http://localhost:3000".
+	Target string `json:"target" jsonschema:"title=Target URL,required"`
+
+	// Whether the path prefix should be removed before forwarding, i.e. /api/users -> /users.
+	StripPrefix bool `json:"stripPrefix,omitempty" jsonschema:"title=Strip path prefix"`
+	// Whether the Host header should be rewritten to the host of the target.
+	ChangeOrigin bool `json:"changeOrigin,omitempty" jsonschema:"title=Change origin"`
+
+	// Headers which are set on every forwarded request. An empty value removes the header instead.
+	Headers map[string]string `json:"headers,omitempty" jsonschema:"title=Request headers"`
+	// Headers which are set on every response from the target. An empty value removes the header instead.
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty" jsonschema:"title=Response headers"`
 }
 
 //type ResourceHints struct {
@@ -88,6 +200,8 @@ type ProjectConfig struct {
 	Input string `json:"input,omitempty" jsonschema:"title=Input directory"`
 	// The directory where the project's components are stored.
 	Components string `json:"components,omitempty" jsonschema:"title=Components directory"`
+	// The directory of JSON, YAML and TOML files which are available to every page as data, and to hooks as sklair.data.
+	Data string `json:"data,omitempty" jsonschema:"title=Data directory"`
 
 	// A list of gitignore-style glob patterns that should be excluded from the build process.
 	Exclude []string `json:"exclude,omitempty" jsonschema:"title=Exclude patterns"`
@@ -96,6 +210,8 @@ type ProjectConfig struct {
 
 	// The directory where the built project should be written to.
 	Output string `json:"output,omitempty" jsonschema:"title=Output directory"`
+	// The public URL of the site, e.g. "https://example.com", which is needed for the absolute links in sitemaps and feeds.
+	SiteURL string `json:"siteURL,omitempty" jsonschema:"title=Site URL,format=uri"`
 
 	// Whether HTML files should be minified during the build process.
 	//
@@ -106,9 +222,27 @@ type ProjectConfig struct {
 	// This field does not affect output at the moment as it is intended for the future.
 	ObfuscateJS *ObfuscateJS `json:"obfuscateJS,omitempty" jsonschema:"title=Obfuscate JavaScript"`
 
+	// Whether relative URLs in 404.html and 404.shtml are rewritten to root-anchored paths, i.e. "styles/main.css" to "/styles/main.css".
+	// 404 pages are served for missing pages at any depth, where relative URLs break. Without this, they are only warned about.
+	RootAnchor404 bool `json:"rootAnchor404,omitempty" jsonschema:"title=Root-anchor URLs in 404 pages"`
+
 	// Options for preventing Flash Of Unstyled Content (FOUC) in the final outputted HTML.
 	PreventFOUC *PreventFOUC `json:"preventFOUC,omitempty" jsonschema:"title=Prevent FOUC"`
+	// Options for compiling Markdown files into HTML pages.
+	Markdown *Markdown `json:"markdown,omitempty" jsonschema:"title=Markdown"`
+	// Lists of pages or data entries, which are available to every page as collections.<name>
+	// and can generate item, list and tag pages from template components.
+	Collections map[string]*Collection `json:"collections,omitempty" jsonschema:"title=Collections"`
+	// Options for generating sitemap.xml and robots.txt, which require the site URL.
+	Sitemap *Sitemap `json:"sitemap,omitempty" jsonschema:"title=Sitemap"`
+	// RSS, Atom and JSON feeds of collections or directories of pages, which require the site URL.
+	// The title, date and summary of each entry come from its front matter, or otherwise from the page itself.
+	Feeds []*Feed `json:"feeds,omitempty" jsonschema:"title=Feeds"`
 	//ResourceHints *ResourceHints `json:"resourceHints,omitempty"` // TODO: in sklair init, add ResourceHints to the questionnaire
+
+	// Requests that the development server (sklair serve) forwards to other servers, e.g. a backend API.
+	// WebSocket connections are forwarded as well.
+	Proxy []*ProxyRule `json:"proxy,omitempty" jsonschema:"title=Development server proxy"`
 }
 
 var DefaultConfig = ProjectConfig{
@@ -129,6 +263,7 @@ var DefaultConfig = ProjectConfig{
 
 	Input:      "./src",
 	Components: "./components",
+	Data:       "./data",
 
 	Exclude:        []string{},
 	ExcludeCompile: []string{},
@@ -144,6 +279,12 @@ var DefaultConfig = ProjectConfig{
 		Enabled: false,
 		Colour:  "#202020",
 	},
+	Markdown: &Markdown{
+		Enabled: false,
+	},
+	Sitemap: &Sitemap{
+		Enabled: false,
+	},
 	//ResourceHints: &ResourceHints{
 	//	Enabled:    false,
 	//	SiteOrigin: "https://sklair.numelon.com", // TODO: maybe just make it empty by default
@@ -43,18 +43,23 @@ func newMetaNode(name, property, content string) *html.Node {
 
 */
 
-func OpenGraph(originalTag *html.Node) []*html.Node {
+// OpenGraph expands an <OpenGraph> tag into meta tags.
+// defaults are used for every attribute that the tag does not set itself, i.e. the title and description from front matter
+func OpenGraph(originalTag *html.Node, defaults map[string]string) []*html.Node {
 	var out []*html.Node
 
 	var (
-		siteName    string
-		title       string
-		description string
-		image       string
-		url         string
+		siteName    = defaults["site_name"]
+		title       = defaults["title"]
+		description = defaults["description"]
+		image       = defaults["image"]
+		url         = defaults["url"]
 		ogType      = "website" // default
 		imageSize   = "large"   // default
 	)
+	if t := defaults["type"]; t != "" {
+		ogType = t
+	}
 
 	for _, attr := range originalTag.Attr {
 		switch attr.Key {